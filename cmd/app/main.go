@@ -2,106 +2,236 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/sayhellolexa/order-service/internal/config"
+	domaincache "github.com/sayhellolexa/order-service/internal/domain/cache"
+	"github.com/sayhellolexa/order-service/internal/health"
+	"github.com/sayhellolexa/order-service/internal/logger"
+	"github.com/sayhellolexa/order-service/internal/metrics"
 	"github.com/sayhellolexa/order-service/internal/repository/cache"
 	"github.com/sayhellolexa/order-service/internal/repository/postgres"
 	"github.com/sayhellolexa/order-service/internal/server"
+	"github.com/sayhellolexa/order-service/internal/tracing"
+	"github.com/sayhellolexa/order-service/migrations"
 )
 
+// appConfig - настройки HTTP-приложения
+type appConfig struct {
+	HTTP       config.HTTP       `yaml:"http"`
+	Postgres   config.Postgres   `yaml:"postgres"`
+	Redis      config.Redis      `yaml:"redis"`
+	Cache      config.Cache      `yaml:"cache"`
+	LocalCache config.LocalCache `yaml:"local_cache"`
+	Preload    config.Preload    `yaml:"preload"`
+	Reconcile  config.Reconcile  `yaml:"reconcile"`
+	Log        config.Log        `yaml:"log"`
+	Tracing    config.Tracing    `yaml:"tracing"`
+	Metrics    config.Metrics    `yaml:"metrics"`
+}
+
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		err = fmt.Errorf("error loading .env file: %w", err)
+	if err := run(); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	serverAddr := os.Getenv("SERVER_ADDR")
-	if serverAddr == "" {
-		log.Fatal("SERVER_ADDR environment variable not set")
+func run() error {
+	var cfg appConfig
+	err := config.Load(&cfg, flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return nil
 	}
-
-	databaseName := os.Getenv("DATABASE_NAME")
-	if databaseName == "" {
-		log.Fatal("DATABASE_NAME environment variable not set")
+	if err != nil {
+		return err
 	}
 
-	databaseHost := os.Getenv("DATABASE_HOST")
-	if databaseHost == "" {
-		log.Fatal("DATABASE_HOST environment variable not set")
-	}
+	appLogger := logger.New(os.Stdout, cfg.Log.Settings())
+	slog.SetDefault(appLogger)
 
-	databasePort := os.Getenv("DATABASE_PORT")
-	if databasePort == "" {
-		log.Fatal("DATABASE_PORT environment variable not set")
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Settings("order-app"))
+	if err != nil {
+		return err
 	}
+	// Трассировка выгружается последней, после закрытия клиентов
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			appLogger.Error("Failed to shutdown tracing", "error", err)
+		}
+	}()
+
+	pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	databaseUser := os.Getenv("DATABASE_USER")
-	if databaseUser == "" {
-		log.Fatal("DATABASE_USER environment variable not set")
+	pool, err := postgres.NewPool(pingCtx, cfg.Postgres.Settings())
+	if err != nil {
+		return err
 	}
-
-	databasePass := os.Getenv("DATABASE_PASS")
-	if databasePass == "" {
-		log.Fatal("DATABASE_PASS environment variable not set")
+	defer pool.Close()
+	postgres.RegisterPoolMetrics(pool)
+
+	// database/sql нужен кешу и миграциям и работает поверх того же пула
+	db := postgres.OpenDB(pool)
+	defer func() {
+		if err := db.Close(); err != nil {
+			appLogger.Error("Failed to close database", "error", err)
+		}
+	}()
+
+	if err := pool.Ping(pingCtx); err != nil {
+		return fmt.Errorf("unable to ping database: %w", err)
 	}
 
-	databaseSsl := os.Getenv("DATABASE_SSL_MODE")
-	if databaseSsl == "" {
-		log.Fatal("DATABASE_SSL_MODE environment variable not set")
+	// Реплики, запущенные одновременно, применяют миграции по очереди
+	if cfg.Postgres.AutoMigrate {
+		migrator, err := postgres.NewMigrator(db, migrations.FS, appLogger)
+		if err != nil {
+			return err
+		}
+		if err := migrator.Up(context.Background()); err != nil {
+			return err
+		}
 	}
 
-	settings := postgres.Settings{
-		User: databaseUser,
-		Pass: databasePass,
-		Host:  databaseHost,
-		Port: databasePort, 
-		Name: databaseName,
-		SslMode: databaseSsl,
-		Reload: false,
+	redisOpts, err := cfg.Redis.Options()
+	if err != nil {
+		return err
 	}
-
-	redisAddress := os.Getenv("REDIS_URL")
-	if redisAddress == "" {
-		log.Fatal("REDIS_URL environment variable not set")
+	rdb := redis.NewClient(redisOpts)
+	// Redis закрывается раньше PostgreSQL: отложенные вызовы идут в обратном порядке
+	defer func() {
+		if err := rdb.Close(); err != nil {
+			appLogger.Error("Failed to close Redis client", "error", err)
+		}
+	}()
+
+	if err := rdb.Ping(pingCtx).Err(); err != nil {
+		return fmt.Errorf("unable to connect to Redis: %w", err)
 	}
 
-	db, err := postgres.Connect(settings)
-	if err != nil {
-		log.Fatal(err)
+	// SIGINT и SIGTERM останавливают сервер и фоновые задачи
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	// Фоновые задачи должны завершиться до закрытия Redis и PostgreSQL
+	var background sync.WaitGroup
+	defer background.Wait()
+	defer stop()
+
+	pgRepo := postgres.NewPoolOrderRepository(pool, db, postgres.WithLogger(appLogger))
+	redisCache := cache.NewRedisCacheRepository(rdb, db,
+		cache.WithInvalidation(cfg.Redis.InvalidationChannel),
+		cache.WithPolicy(cfg.Cache.Policy()),
+		cache.WithLogger(appLogger),
+	)
+
+	// Локальный кеш включён по умолчанию, LOCAL_CACHE_SIZE=0 его отключает
+	var cacheRepo domaincache.Repository = redisCache
+	var reconciler domaincache.Reconciler = redisCache
+	if cfg.LocalCache.Size > 0 {
+		localCache := cache.NewLocalCache(redisCache,
+			cache.WithLocalSize(cfg.LocalCache.Size),
+			cache.WithLocalTTL(cfg.LocalCache.TTL),
+			cache.WithStaleWhileRevalidate(cfg.LocalCache.Stale),
+		)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			localCache.Listen(ctx)
+		}()
+		cacheRepo = localCache
+		reconciler = localCache
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	if err := db.PingContext(ctx); err != nil {
-		log.Fatalf("Unable to ping database: %v", err)
+	// Сверка кеша с БД по расписанию включается RECONCILE_INTERVAL
+	if cfg.Reconcile.Interval > 0 {
+		reconcileOpts := domaincache.ReconcileOptions{BatchSize: cfg.Reconcile.BatchSize}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			reconcileEvery(ctx, reconciler, cfg.Reconcile.Interval, reconcileOpts)
+		}()
 	}
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisAddress,
-		Password: "", 
-		DB:       0,
+	// Готовность к трафику зависит от PostgreSQL и Redis, а также
+	// от прогрева кеша, проверку которого добавляет сервер
+	checker := health.NewChecker(health.DefaultTimeout)
+	checker.Add("postgres", pool.Ping)
+	checker.Add("redis", func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
 	})
 
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Unable to connect to Redis: %v", err)
+	preloadOpts := domaincache.PreloadOptions{
+		BatchSize:   cfg.Preload.BatchSize,
+		Concurrency: cfg.Preload.Concurrency,
+		Limit:       cfg.Preload.Limit,
+		CreatedFrom: cfg.Preload.CreatedFrom,
+		CreatedTo:   cfg.Preload.CreatedTo,
+		IfEmpty:     true,
 	}
 
-	pgRepo := postgres.NewOrderRepository(db)
-	cacheRepo := cache.NewRedisCacheRepository(rdb, db)
-
-	s := server.NewServer(pgRepo, cacheRepo)
-
-	if err := s.Start(serverAddr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Метрики и статистика кеша отдаются на отдельном служебном порту, а не рядом с API
+	metricsServer := metrics.Serve(cfg.Metrics.Addr,
+		metrics.WithHandler("GET /cache/stats", server.CacheStatsHandler(cacheRepo, appLogger)),
+	)
+	defer func() {
+		if err := metricsServer.Close(); err != nil {
+			appLogger.Error("Failed to close metrics server", "error", err)
+		}
+	}()
+
+	s := server.NewServer(pgRepo, cacheRepo,
+		server.WithLogger(appLogger),
+		server.WithHealth(checker),
+		server.WithPageSize(cfg.HTTP.PageSize, max(cfg.HTTP.PageSize, 100)),
+		server.WithNotFoundTTL(cfg.Cache.NotFoundTTL),
+		server.WithPreload(preloadOpts, cfg.Preload.Timeout),
+		server.WithTimeouts(cfg.HTTP.ReadTimeout, cfg.HTTP.WriteTimeout, cfg.HTTP.IdleTimeout),
+		server.WithShutdownTimeout(cfg.HTTP.ShutdownTimeout),
+	)
+
+	// Start возвращается после сигнала остановки, дождавшись текущих
+	// запросов и фоновых загрузок в кеш. Затем отложенные вызовы
+	// останавливают фоновые задачи и закрывают клиентов
+	if err := s.Start(ctx, cfg.HTTP.Addr); err != nil {
+		return err
 	}
 
-	log.Print("Server is working...")
-}
\ No newline at end of file
+	appLogger.Info("Server stopped")
+	return nil
+}
+
+// reconcileEvery сверяет кеш с БД раз в interval, пока не будет отменён ctx.
+// Если сверку уже выполняет другая реплика, запуск пропускается
+func reconcileEvery(ctx context.Context, reconciler domaincache.Reconciler, interval time.Duration, opts domaincache.ReconcileOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := reconciler.Reconcile(ctx, opts)
+		switch {
+		case errors.Is(err, cache.ErrReconcileRunning):
+			slog.Info("Cache reconciliation is already running on another instance, skipping")
+		case err != nil:
+			slog.Error("Cache reconciliation failed", "error", err)
+		case report.Fixed() > 0:
+			slog.Warn("Cache reconciliation fixed entries", "fixed", report.Fixed(), "order_uids", report.Mismatched)
+		}
+	}
+}
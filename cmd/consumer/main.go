@@ -2,92 +2,306 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/sayhellolexa/order-service/internal/config"
+	"github.com/sayhellolexa/order-service/internal/health"
 	"github.com/sayhellolexa/order-service/internal/kafka"
 	"github.com/sayhellolexa/order-service/internal/kafka/handler"
+	"github.com/sayhellolexa/order-service/internal/kafka/retry"
+	"github.com/sayhellolexa/order-service/internal/logger"
+	"github.com/sayhellolexa/order-service/internal/metrics"
 	"github.com/sayhellolexa/order-service/internal/repository/cache"
 	"github.com/sayhellolexa/order-service/internal/repository/postgres"
+	"github.com/sayhellolexa/order-service/internal/tracing"
 )
 
+// Сколько ждать завершения обработки текущего сообщения после сигнала остановки.
+// Столько же обработчикам даётся на завершение, после чего их контекст отменяется
+const shutdownTimeout = 30 * time.Second
+
+// Сколько ждать возврата Start после принудительного закрытия консьюмеров,
+// прежде чем закрыть пулы и клиенты, с которыми работают обработчики
+const closeTimeout = 5 * time.Second
+
+// consumerConfig - настройки консьюмера заказов
+type consumerConfig struct {
+	Kafka    config.Kafka    `yaml:"kafka"`
+	Consumer config.Consumer `yaml:"consumer"`
+	Postgres config.Postgres `yaml:"postgres"`
+	Redis    config.Redis    `yaml:"redis"`
+	Cache    config.Cache    `yaml:"cache"`
+	Log      config.Log      `yaml:"log"`
+	Tracing  config.Tracing  `yaml:"tracing"`
+	Metrics  config.Metrics  `yaml:"metrics"`
+}
+
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		err = fmt.Errorf("error loading .env file: %w", err)
+	if err := run(); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	brokers := os.Getenv("KAFKA_BROKERS")
-	if brokers == "" {
-		log.Fatal("KAFKA_BROKERS environment variable not set")
+func run() error {
+	cfg := consumerConfig{
+		// Служебный порт приложения занят, если оба процесса запущены рядом
+		Metrics:  config.Metrics{Addr: ":9091"},
+		Consumer: config.Consumer{Retry: defaultRetry()},
 	}
-
-	topic := os.Getenv("KAFKA_TOPIC")
-	if topic == "" {
-		log.Fatal("KAFKA_TOPIC environment variable not set")
+	err := config.Load(&cfg, flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return nil
 	}
-
-	consumerGroup := os.Getenv("KAFKA_CONSUMER_GROUP")
-	if consumerGroup == "" {
-		log.Fatal("KAFKA_CONSUMER_GROUP environment variable not set")
+	if err != nil {
+		return err
 	}
 
-	dataBaseUrl := os.Getenv("DATABASE_URL")
-	if dataBaseUrl == "" {
-		log.Fatal("DATABASE_URL environment variable not set")
-	}
+	appLogger := logger.New(os.Stdout, cfg.Log.Settings())
+	slog.SetDefault(appLogger)
 
-	redisAddress := os.Getenv("REDIS_URL")
-	if redisAddress == "" {
-		log.Fatal("REDIS_URL environment variable not set")
+	conflictPolicy, err := postgres.ParseConflictPolicy(cfg.Consumer.ConflictPolicy)
+	if err != nil {
+		return err
 	}
 
-	db, err := sql.Open("pgx", dataBaseUrl)
+	pool, err := postgres.NewPool(context.Background(), cfg.Postgres.Settings())
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v", err)
+		return fmt.Errorf("unable to connect to database: %w", err)
 	}
+	defer pool.Close()
+	postgres.RegisterPoolMetrics(pool)
+
+	db := postgres.OpenDB(pool)
 	defer db.Close()
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr: redisAddress,
-		Password: "",
-		DB: 0,
-	})
+	redisOpts, err := cfg.Redis.Options()
+	if err != nil {
+		return err
+	}
+	rdb := redis.NewClient(redisOpts)
+	defer rdb.Close()
 
 	err = rdb.Ping(context.Background()).Err()
 	if err != nil {
-		log.Fatalf("Unable to connect to Redis: %v", err)
+		return fmt.Errorf("unable to connect to Redis: %w", err)
 	}
 
-	cache := cache.NewRedisCacheRepository(rdb, db)	
+	// Изменённые заказы публикуются в канал инвалидации, чтобы реплики
+	// приложения сбросили их из локального кеша
+	cache := cache.NewRedisCacheRepository(rdb, db,
+		cache.WithInvalidation(cfg.Redis.InvalidationChannel),
+		cache.WithPolicy(cfg.Cache.Policy()),
+		cache.WithLogger(appLogger),
+	)
 
-	repo := postgres.NewOrderRepository(db)
+	repo := postgres.NewPoolOrderRepository(pool, db, postgres.WithLogger(appLogger), postgres.WithConflictPolicy(conflictPolicy))
 
-	handler := handler.NewHandler(repo, cache)
+	handlerOpts := []handler.Option{handler.WithLogger(appLogger)}
+	orderHandler := handler.NewHandler(repo, cache, handlerOpts...)
 
-	c, err := kafka.NewConsumer([]string{brokers}, consumerGroup, topic, handler)
+	// Общие опции: политики повторов и DLQ действуют для обоих топиков
+	common := []kafka.Option{kafka.WithLogger(appLogger), kafka.WithShutdownTimeout(shutdownTimeout)}
+
+	for class, setting := range map[retry.Class]config.RetryPolicy{
+		retry.ClassTransient: cfg.Consumer.Retry.Transient,
+		retry.ClassPermanent: cfg.Consumer.Retry.Permanent,
+	} {
+		common = append(common, kafka.WithRetryPolicy(class, retryPolicy(setting)))
+	}
+
+	brokers := cfg.Kafka.Brokers
+
+	// Dead-letter топик необязателен: без него сбойные сообщения только логируются
+	if cfg.Consumer.DLQTopic != "" {
+		producer, err := kafka.NewProducer(brokers)
+		if err != nil {
+			return fmt.Errorf("failed to create dead-letter producer: %w", err)
+		}
+		defer producer.Close()
+
+		common = append(common, kafka.WithDeadLetterQueue(kafka.NewDeadLetterQueue(producer, cfg.Consumer.DLQTopic)))
+	}
+
+	opts := append([]kafka.Option{}, common...)
+	if cfg.Consumer.Workers > 1 {
+		opts = append(opts, kafka.WithWorkers(cfg.Consumer.Workers))
+	}
+	if cfg.Consumer.BatchSize > 1 {
+		opts = append(opts, kafka.WithBatch(cfg.Consumer.BatchSize, cfg.Consumer.BatchWait))
+	}
+
+	c, err := kafka.NewConsumer(brokers, cfg.Consumer.Group, cfg.Kafka.Topic, orderHandler, opts...)
 	if err != nil {
-		log.Fatalf("Failed to create consumer: %v", err)
+		return fmt.Errorf("failed to create consumer: %w", err)
 	}
 
 	if c == nil {
-		log.Fatal("Consumer is nil")
+		return errors.New("consumer is nil")
 	}
 
-	go c.Start()
+	consumers := []*kafka.Consumer{c}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Топик событий смены статуса необязателен. Его консьюмер обрабатывает
+	// события по одному: порядок переходов внутри партиции важен
+	if statusTopic := cfg.Consumer.StatusTopic; statusTopic != "" {
+		statusHandler := handler.NewStatusHandler(repo, cache, handlerOpts...)
+		sc, err := kafka.NewConsumer(brokers, cfg.Consumer.Group, statusTopic, statusHandler, common...)
+		if err != nil {
+			return fmt.Errorf("failed to create status consumer: %w", err)
+		}
+		consumers = append(consumers, sc)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Settings("order-consumer"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			appLogger.Error("Failed to shutdown tracing", "error", err)
+		}
+	}()
 
-	<-sigChan
-	log.Fatal(c.Stop())
+	// Метрики и проверки здоровья отдаются на отдельном служебном порту
+	metricsServer := metrics.Serve(cfg.Metrics.Addr,
+		metrics.WithHandler("/healthz", health.LiveHandler()),
+		metrics.WithHandler("/readyz", consumerChecker(consumers)),
+	)
+	defer metricsServer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan error, len(consumers))
+	for _, consumer := range consumers {
+		go func() {
+			done <- consumer.Start(ctx)
+		}()
+	}
+
+	// Первый остановившийся консьюмер останавливает и остальные
+	var errs []error
+	select {
+	case err := <-done:
+		errs = append(errs, err)
+		stop()
+	case <-ctx.Done():
+	}
+
+	appLogger.Info("Shutting down consumer")
+
+	// Даём текущим сообщениям догрузиться, но не ждём бесконечно
+	timeout := time.After(shutdownTimeout)
+	for len(errs) < len(consumers) {
+		select {
+		case err := <-done:
+			errs = append(errs, err)
+		case <-timeout:
+			closeConsumers(consumers, done, len(consumers)-len(errs), appLogger)
+			return errors.New("consumer shutdown timed out")
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	appLogger.Info("Consumer stopped")
+	return nil
+}
+
+// closeConsumers отменяет обработку и закрывает консьюмеры, которые не
+// остановились вовремя, и ждёт не дольше closeTimeout, пока running
+// оставшихся вызовов Start вернутся. Так отложенное закрытие пулов
+// и клиентов происходит после выхода обработчиков
+func closeConsumers(consumers []*kafka.Consumer, done <-chan error, running int, logger *slog.Logger) {
+	// Close ждёт текущего чтения из Kafka, поэтому тоже ограничен closeTimeout
+	go func() {
+		for _, consumer := range consumers {
+			if err := consumer.Close(); err != nil {
+				logger.Error("Failed to close consumer", "topic", consumer.Topic(), "error", err)
+			}
+		}
+	}()
+
+	timeout := time.After(closeTimeout)
+	for ; running > 0; running-- {
+		select {
+		case <-done:
+		case <-timeout:
+			logger.Error("Consumer handlers did not stop after close", "running", running)
+			return
+		}
+	}
+}
+
+// consumerChecker проверяет связь каждого консьюмера с брокером и сообщает,
+// сколько прошло с последнего успешно обработанного сообщения. Простой
+// топика готовность не снимает: сообщений может просто не быть
+func consumerChecker(consumers []*kafka.Consumer) http.Handler {
+	checker := health.NewChecker(health.DefaultTimeout)
+	for _, c := range consumers {
+		checker.Add("kafka:"+c.Topic(), c.Ping)
+	}
+
+	checker.AddInfo("last_handled", func() any {
+		info := make(map[string]any, len(consumers))
+		for _, c := range consumers {
+			last := c.LastHandled()
+			if last.IsZero() {
+				info[c.Topic()] = nil
+				continue
+			}
+			info[c.Topic()] = map[string]any{
+				"at":          last.UTC(),
+				"seconds_ago": time.Since(last).Seconds(),
+			}
+		}
+		return info
+	})
+
+	return checker.ReadyHandler()
+}
+
+// defaultRetry описывает политики повторов по умолчанию в виде настроек,
+// чтобы -print-config показывал действующие значения
+func defaultRetry() config.Retry {
+	policies := retry.DefaultPolicies()
+	setting := func(p retry.Policy) config.RetryPolicy {
+		return config.RetryPolicy{
+			MaxAttempts:    p.MaxAttempts,
+			InitialBackoff: p.InitialBackoff,
+			MaxBackoff:     p.MaxBackoff,
+			Multiplier:     p.Multiplier,
+			Jitter:         p.Jitter,
+		}
+	}
+	return config.Retry{
+		Transient: setting(policies[retry.ClassTransient]),
+		Permanent: setting(policies[retry.ClassPermanent]),
+	}
+}
+
+// retryPolicy переводит настройки в политику повторов. Настройки заранее
+// заполнены значениями по умолчанию из defaultRetry, поэтому каждое поле,
+// в том числе нулевое, задаётся явно: max_attempts 0 или 1 отключает повторы
+func retryPolicy(setting config.RetryPolicy) retry.Policy {
+	return retry.Policy{
+		MaxAttempts:    setting.MaxAttempts,
+		InitialBackoff: setting.InitialBackoff,
+		MaxBackoff:     setting.MaxBackoff,
+		Multiplier:     setting.Multiplier,
+		Jitter:         setting.Jitter,
+	}
 }
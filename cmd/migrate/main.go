@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sayhellolexa/order-service/internal/config"
+	"github.com/sayhellolexa/order-service/internal/logger"
+	"github.com/sayhellolexa/order-service/internal/repository/postgres"
+	"github.com/sayhellolexa/order-service/migrations"
+)
+
+// migrateConfig - настройки команды миграций
+type migrateConfig struct {
+	Postgres config.Postgres `yaml:"postgres"`
+	Log      config.Log      `yaml:"log"`
+}
+
+const usage = `Usage: migrate [flags] <command>
+
+Commands:
+  up          apply all pending migrations
+  down        roll back the last applied migration
+  redo        roll back and reapply the last migration
+  status      print applied and pending migrations
+  to VERSION  migrate up or down to VERSION (0 rolls back everything)
+
+Flags:
+`
+
+// Применение встроенных миграций схемы PostgreSQL
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	var cfg migrateConfig
+	err := config.Load(&cfg, flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+
+	// stdout занят выводом status, поэтому логи пишутся в stderr
+	appLogger := logger.New(os.Stderr, cfg.Log.Settings())
+	slog.SetDefault(appLogger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := postgres.NewPool(ctx, cfg.Postgres.Settings())
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	db := postgres.OpenDB(pool)
+	defer db.Close()
+
+	migrator, err := postgres.NewMigrator(db, migrations.FS, appLogger)
+	if err != nil {
+		return err
+	}
+
+	switch command := args[0]; command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "redo":
+		return migrator.Redo(ctx)
+	case "status":
+		return printStatus(ctx, migrator)
+	case "to":
+		if len(args) < 2 {
+			return errors.New("missing version for command to")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return migrator.To(ctx, version)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// printStatus печатает таблицу миграций
+func printStatus(ctx context.Context, migrator *postgres.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED AT\tNAME")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, appliedAt, s.Name)
+	}
+	return w.Flush()
+}
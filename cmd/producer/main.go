@@ -1,30 +1,69 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"math"
 	"math/rand"
 	"os"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/sayhellolexa/order-service/internal/config"
 	"github.com/sayhellolexa/order-service/internal/kafka"
+	"github.com/sayhellolexa/order-service/internal/logger"
 	model "github.com/sayhellolexa/order-service/internal/model"
+	"github.com/sayhellolexa/order-service/internal/tracing"
 )
 
+// randomOrder генерирует заказ, который проходит валидацию: суммы оплаты
+// сходятся с товарами, а трек-номер товаров совпадает с трек-номером заказа
 func randomOrder() model.Order {
 	now := time.Now().UTC()
 	orderUID := uuid.New().String()
+	trackNumber := fmt.Sprintf("TRACK-%d", rand.Intn(100000))
+
+	items := make([]model.Item, rand.Intn(3)+1)
+	var goodsTotal model.Money
+	for i := range items {
+		units := rand.Intn(1000) + 100
+		sale := rand.Intn(50)
+		price := model.Money(units) * model.Unit
+		totalPrice := model.Money(math.Round(float64(units*(100-sale))/100)) * model.Unit
+
+		items[i] = model.Item{
+			ChrtID:      rand.Intn(9999999) + 1,
+			TrackNumber: trackNumber,
+			Price:       price,
+			Rid:         uuid.New().String(),
+			Name:        randomProduct(),
+			Sale:        sale,
+			Size:        fmt.Sprintf("%d", rand.Intn(5)),
+			TotalPrice:  totalPrice,
+			NmID:        rand.Intn(9999999),
+			Brand:       randomBrand(),
+			Status:      202,
+		}
+		goodsTotal += totalPrice
+	}
+
+	deliveryCost := 1500 * model.Unit
 
 	return model.Order{
 		OrderUID:    orderUID,
-		TrackNumber: fmt.Sprintf("TRACK-%d", rand.Intn(100000)),
+		TrackNumber: trackNumber,
 		Entry:       "WBIL",
 		Delivery: model.Delivery{
 			Name:    randomName(),
-			Phone:   fmt.Sprintf("+97200%d", rand.Intn(9999999)),
+			Phone:   fmt.Sprintf("+97200%07d", rand.Intn(9999999)),
 			Zip:     fmt.Sprintf("%06d", rand.Intn(999999)),
 			City:    "Kiryat Mozkin",
 			Address: fmt.Sprintf("Street %d, House %d", rand.Intn(50), rand.Intn(100)),
@@ -36,28 +75,14 @@ func randomOrder() model.Order {
 			RequestID:    "",
 			Currency:     "USD",
 			Provider:     "wbpay",
-			Amount:       float64(rand.Intn(5000) + 500),
+			Amount:       goodsTotal + deliveryCost,
 			PaymentDt:    now.Unix(),
 			Bank:         "alpha",
-			DeliveryCost: 1500,
-			GoodsTotal:   float64(rand.Intn(2000) + 100),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
 			CustomFee:    0,
 		},
-		Items: []model.Item{
-			{
-				ChrtID:      rand.Intn(9999999),
-				TrackNumber: fmt.Sprintf("TRACK-%d", rand.Intn(100000)),
-				Price:       float64(rand.Intn(1000) + 100),
-				Rid:         uuid.New().String(),
-				Name:        randomProduct(),
-				Sale:        rand.Intn(50),
-				Size:        fmt.Sprintf("%d", rand.Intn(5)),
-				TotalPrice:  float64(rand.Intn(2000) + 100),
-				NmID:        rand.Intn(9999999),
-				Brand:       randomBrand(),
-				Status:      202,
-			},
-		},
+		Items:             items,
 		Locale:            "ru",
 		InternalSignature: "",
 		CustomerID:        "test",
@@ -84,25 +109,41 @@ func randomBrand() string {
 	return brands[rand.Intn(len(brands))]
 }
 
+// producerConfig - настройки генератора тестовых заказов
+type producerConfig struct {
+	Kafka   config.Kafka   `yaml:"kafka"`
+	Log     config.Log     `yaml:"log"`
+	Tracing config.Tracing `yaml:"tracing"`
+}
+
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
-	err := godotenv.Load()
+	var cfg producerConfig
+	err := config.Load(&cfg, flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("error loading .env file: %v", err)
+		log.Fatal(err)
 	}
 
-	brokers := os.Getenv("KAFKA_BROKERS")
-	if brokers == "" {
-		log.Fatal("KAFKA_BROKERS environment variable not set")
-	}
+	appLogger := logger.New(os.Stdout, cfg.Log.Settings())
+	slog.SetDefault(appLogger)
 
-	topic := os.Getenv("KAFKA_TOPIC")
-	if topic == "" {
-		log.Fatal("KAFKA_TOPIC environment variable not set")
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Settings("order-producer"))
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			appLogger.Error("Failed to shutdown tracing", "error", err)
+		}
+	}()
+
+	tracer := otel.Tracer("github.com/sayhellolexa/order-service/cmd/producer")
 
-	producer, err := kafka.NewProducer([]string{brokers})
+	producer, err := kafka.NewProducer(cfg.Kafka.Brokers)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -112,17 +153,24 @@ func main() {
 	for range 10 {
 		order := randomOrder()
 
+		// Каждый заказ начинает свою трассировку, которую продолжит консьюмер
+		ctx, span := tracer.Start(context.Background(), "generate order")
+		span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+
 		data, err := json.Marshal(order)
 		if err != nil {
-			log.Printf("failed to marshal order: %v", err)
+			appLogger.ErrorContext(ctx, "Failed to marshal order", "order_uid", order.OrderUID, "error", err)
+			tracing.End(span, err)
 			continue
 		}
 
-		if err := producer.Produce(topic, data); err != nil {
-			log.Printf("failed to produce message: %v", err)
+		err = producer.ProduceWithKey(ctx, cfg.Kafka.Topic, []byte(order.OrderUID), data)
+		if err != nil {
+			appLogger.ErrorContext(ctx, "Failed to produce message", "order_uid", order.OrderUID, "error", err)
 		} else {
-			log.Printf("Produced order %s", order.OrderUID)
+			appLogger.InfoContext(ctx, "Produced order", "order", order)
 		}
+		tracing.End(span, err)
 
 		time.Sleep(1 * time.Second) // задержка, чтобы не спамить слишком быстро
 	}
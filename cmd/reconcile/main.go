@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/sayhellolexa/order-service/internal/config"
+	domaincache "github.com/sayhellolexa/order-service/internal/domain/cache"
+	"github.com/sayhellolexa/order-service/internal/logger"
+	"github.com/sayhellolexa/order-service/internal/repository/cache"
+	"github.com/sayhellolexa/order-service/internal/repository/postgres"
+)
+
+// reconcileConfig - настройки сверки кеша
+type reconcileConfig struct {
+	Postgres config.Postgres `yaml:"postgres"`
+	Redis    config.Redis    `yaml:"redis"`
+	Cache    config.Cache    `yaml:"cache"`
+	Log      config.Log      `yaml:"log"`
+}
+
+// Однократная сверка кеша Redis с PostgreSQL. Отчёт печатается в stdout в JSON
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	var opts domaincache.ReconcileOptions
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "only report mismatches, do not repair them")
+	flag.IntVar(&opts.BatchSize, "batch", 0, "number of keys compared per batch")
+
+	var cfg reconcileConfig
+	err := config.Load(&cfg, flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// stdout занят отчётом, поэтому логи пишутся в stderr
+	appLogger := logger.New(os.Stderr, cfg.Log.Settings())
+	slog.SetDefault(appLogger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := postgres.NewPool(ctx, cfg.Postgres.Settings())
+	if err != nil {
+		return fmt.Errorf("unable to connect to database: %w", err)
+	}
+	defer pool.Close()
+
+	db := postgres.OpenDB(pool)
+	defer db.Close()
+
+	redisOpts, err := cfg.Redis.Options()
+	if err != nil {
+		return err
+	}
+	rdb := redis.NewClient(redisOpts)
+	defer rdb.Close()
+
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("unable to connect to Redis: %w", err)
+	}
+
+	// Исправления публикуются в канал инвалидации, чтобы реплики приложения
+	// сбросили заказы из локального кеша, и записываются по той же политике,
+	// что у приложения и консьюмера
+	redisCache := cache.NewRedisCacheRepository(rdb, db,
+		cache.WithInvalidation(cfg.Redis.InvalidationChannel),
+		cache.WithPolicy(cfg.Cache.Policy()),
+		cache.WithLogger(appLogger),
+	)
+
+	report, err := redisCache.Reconcile(ctx, opts)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cache reconciliation failed: %w", err)
+	}
+
+	return nil
+}
@@ -2,34 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
-	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/sayhellolexa/order-service/internal/config"
 	cacheRepo "github.com/sayhellolexa/order-service/internal/repository/cache"
 )
 
 func main() {
-	err := godotenv.Load()
+	var cfg struct {
+		Redis config.Redis `yaml:"redis"`
+	}
+	err := config.Load(&cfg, flag.CommandLine, os.Args[1:])
+	if errors.Is(err, config.ErrPrinted) {
+		return
+	}
 	if err != nil {
-		err = fmt.Errorf("error loading .env file: %w", err)
 		log.Fatal(err)
 	}
 
-	redisAddress := os.Getenv("REDIS_URL")
-	if redisAddress == "" {
-		log.Fatal("REDIS_URL environment variable not set")
-	}
-
 	// Создаем клиент Redis
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisAddress,
-		Password: "",
-		DB:       0,
-	})
+	redisOpts, err := cfg.Redis.Options()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rdb := redis.NewClient(redisOpts)
 
 	// Проверяем подключение к Redis
 	ctx := context.Background()
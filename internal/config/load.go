@@ -0,0 +1,286 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPrinted возвращается Load, если запрошен флаг -print-config:
+// итоговая конфигурация уже напечатана, и процесс должен завершиться
+var ErrPrinted = errors.New("config printed")
+
+// ValidationError перечисляет сразу все недостающие и неверные настройки
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// validator реализуют секции, которым мало проверки обязательных полей
+type validator interface {
+	Validate() []string
+}
+
+// Load заполняет cfg - указатель на структуру из секций пакета - по слоям,
+// каждый из которых перекрывает предыдущий:
+//   - значения, заданные в cfg до вызова, и теги default для пустых полей;
+//   - YAML-файл из флага -config или переменной CONFIG_FILE;
+//   - переменные окружения из тегов env, в том числе из файла .env, если он есть;
+//   - флаги командной строки вида -http.addr, по пути полей в YAML.
+//
+// Флаги разбираются в flags, поэтому команда может добавить в него свои.
+// Флаг -print-config печатает итоговую конфигурацию со скрытыми секретами
+func Load(cfg any, flags *flag.FlagSet, args []string) error {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("error with config: expected pointer to struct, got %T", cfg)
+	}
+
+	fields := collect(root.Elem(), nil, "")
+
+	var problems []string
+	for _, f := range fields {
+		if f.def != "" && f.value.IsZero() {
+			if err := setString(f.value, f.def); err != nil {
+				return fmt.Errorf("error with default of %s: %w", f.path, err)
+			}
+		}
+	}
+
+	// Флаги регистрируются до разбора, а применяются после файла и окружения
+	var file string
+	var printConfig bool
+	flags.StringVar(&file, "config", os.Getenv("CONFIG_FILE"), "path to YAML config file (env CONFIG_FILE)")
+	flags.BoolVar(&printConfig, "print-config", false, "print effective config with secrets redacted and exit")
+
+	type flagValue struct {
+		field field
+		value string
+	}
+	var flagValues []flagValue
+	for _, f := range fields {
+		usage := "see " + f.path + " in config file"
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		flags.Func(f.path, usage, func(s string) error {
+			flagValues = append(flagValues, flagValue{field: f, value: s})
+			return nil
+		})
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("error with read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("error with parse config file %s: %w", file, err)
+		}
+	}
+
+	// .env необязателен: в контейнере переменные задаются окружением
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error loading .env file: %w", err)
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(f.env); ok && v != "" {
+			if err := setString(f.value, v); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid %s: %v", f.env, err))
+			}
+		}
+	}
+
+	for _, fv := range flagValues {
+		if err := setString(fv.field.value, fv.value); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid -%s: %v", fv.field.path, err))
+		}
+	}
+
+	for _, f := range fields {
+		if f.required && f.value.IsZero() {
+			problems = append(problems, "missing "+f.name())
+		}
+	}
+	problems = append(problems, validate(root.Elem())...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	if printConfig {
+		if err := Print(os.Stdout, cfg); err != nil {
+			return err
+		}
+		return ErrPrinted
+	}
+
+	return nil
+}
+
+// field - лист конфигурации, который задаётся одной строкой
+type field struct {
+	path     string
+	env      string
+	def      string
+	required bool
+	secret   bool
+	value    reflect.Value
+}
+
+// name описывает поле в сообщениях об ошибках
+func (f field) name() string {
+	if f.env == "" {
+		return f.path
+	}
+	return f.env + " (" + f.path + ")"
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isLeaf сообщает, что значение задаётся строкой, а не вложенной секцией
+func isLeaf(v reflect.Value) bool {
+	return v.Kind() != reflect.Struct || v.Addr().Type().Implements(textUnmarshalerType)
+}
+
+// collect обходит секции и возвращает все листья. Путь складывается
+// из тегов yaml, а имя переменной окружения вложенной секции - из префикса
+// в её теге env и тегов env её полей
+func collect(v reflect.Value, path []string, envPrefix string) []field {
+	var fields []field
+
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+
+		fv := v.Field(i)
+		fieldPath := append(append([]string{}, path...), name)
+		env := sf.Tag.Get("env")
+
+		if !isLeaf(fv) {
+			fields = append(fields, collect(fv, fieldPath, envPrefix+env)...)
+			continue
+		}
+
+		if env != "" {
+			env = envPrefix + env
+		}
+		fields = append(fields, field{
+			path:     strings.Join(fieldPath, "."),
+			env:      env,
+			def:      sf.Tag.Get("default"),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+			value:    fv,
+		})
+	}
+
+	return fields
+}
+
+// validate вызывает Validate у всех секций, которые его реализуют
+func validate(v reflect.Value) []string {
+	var problems []string
+
+	if val, ok := v.Addr().Interface().(validator); ok {
+		problems = append(problems, val.Validate()...)
+	}
+
+	for i := range v.NumField() {
+		fv := v.Field(i)
+		if v.Type().Field(i).IsExported() && !isLeaf(fv) {
+			problems = append(problems, validate(fv)...)
+		}
+	}
+
+	return problems
+}
+
+// setString разбирает строковое значение s в поле v
+func setString(v reflect.Value, s string) error {
+	if v.Kind() != reflect.Pointer && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
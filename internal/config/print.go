@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted заменяет значение секрета при печати конфигурации
+const redacted = "xxxxx"
+
+// Print печатает cfg в YAML в порядке полей. Значения секретов скрываются,
+// а у любых строк вида URL с паролем скрывается только пароль
+func Print(w io.Writer, cfg any) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node(v)); err != nil {
+		return fmt.Errorf("error with print config: %w", err)
+	}
+	return encoder.Close()
+}
+
+// node строит YAML-узел секции. Секреты определяются тем же тегом secret,
+// что и при загрузке
+func node(v reflect.Value) *yaml.Node {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+
+		fv := v.Field(i)
+		var value *yaml.Node
+		switch {
+		case !isLeaf(fv):
+			value = node(fv)
+		case sf.Tag.Get("secret") == "true":
+			value = scalar(redact(format(fv)))
+		case fv.Kind() == reflect.String:
+			value = scalar(redactPassword(fv.String()))
+		case fv.Kind() == reflect.Slice:
+			value = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for j := range fv.Len() {
+				value.Content = append(value.Content, scalar(format(fv.Index(j))))
+			}
+		default:
+			value = scalar(format(fv))
+		}
+
+		mapping.Content = append(mapping.Content, scalar(name), value)
+	}
+
+	return mapping
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
+
+// format возвращает значение так, как его можно задать в переменной окружения
+func format(v reflect.Value) string {
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(text)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// redact скрывает секрет. Пустой секрет остаётся пустым, чтобы было видно,
+// что он не задан
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	if masked := redactPassword(value); masked != value {
+		return masked
+	}
+	return redacted
+}
+
+// redactPassword скрывает пароль в строке вида URL
+func redactPassword(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return value
+}
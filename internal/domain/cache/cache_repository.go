@@ -2,15 +2,34 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	model "github.com/sayhellolexa/order-service/internal/model"
 )
 
+// ErrNotFound возвращается из Get, если в кеше есть отметка,
+// что заказа с таким order_uid нет в БД
+var ErrNotFound = errors.New("order cached as not found")
+
 type Repository interface {
 	Get(ctx context.Context, orderUID string) (*model.Order, error)
-	Set(ctx context.Context, order *model.Order, ttl time.Duration) error
+	// Set кеширует заказ на время, которое задаёт политика кеша
+	Set(ctx context.Context, order *model.Order) error
+	// SetNotFound отмечает на ttl, что заказа нет в БД. Отметка не
+	// перезаписывает уже закешированный заказ и сама удаляется Set
+	SetNotFound(ctx context.Context, orderUID string, ttl time.Duration) error
 	Count(ctx context.Context) (int64, error) 
 	GetAllOrdersIDs(ctx context.Context) ([]string, error)
-	PreloadFromDatabase(ctx context.Context, batchSize int) error
-}
\ No newline at end of file
+	// PreloadFromDatabase прогревает кеш заказами из БД. Прерванный прогрев
+	// с теми же ограничениями продолжается с места остановки
+	PreloadFromDatabase(ctx context.Context, opts PreloadOptions) error
+}
+
+// StaleGetter реализуют кеши, которые умеют отдавать просроченную запись,
+// пока она обновляется в фоне (stale-while-revalidate). Обновление идёт
+// через Get: многоуровневый кеш сначала берёт запись из своего нижнего
+// уровня и только при его промахе обращается к БД
+type StaleGetter interface {
+	GetStale(ctx context.Context, orderUID string) (order *model.Order, stale bool, err error)
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"time"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// DefaultTTL - время жизни заказа в кеше, если политика его не задаёт
+const DefaultTTL = 72 * time.Hour
+
+// Policy решает, сколько заказ живёт в кеше и кешируется ли он вообще.
+// Её применяют все писатели кеша: Set, прогрев и продление при чтении.
+// Нулевые поля не действуют
+type Policy struct {
+	// TTL - время жизни заказа по умолчанию
+	TTL time.Duration
+	// RecentTTL - для заказов, созданных не раньше RecentAge назад
+	RecentTTL time.Duration
+	RecentAge time.Duration
+	// OldTTL - для заказов, созданных раньше OldAge назад
+	OldTTL time.Duration
+	OldAge time.Duration
+	// CompletedTTL - для заказов в конечном статусе: доставленных,
+	// отменённых и возвращённых. Важнее возраста заказа
+	CompletedTTL time.Duration
+	// Sliding продлевает время жизни заказа при каждом чтении из кеша
+	Sliding bool
+	// MaxItems - заказы с большим числом товаров не кешируются
+	MaxItems int
+	// MaxBytes - заказы, JSON которых больше, не кешируются
+	MaxBytes int
+}
+
+// DefaultPolicy - одинаковый TTL для всех заказов без ограничений размера
+func DefaultPolicy() Policy {
+	return Policy{TTL: DefaultTTL}
+}
+
+// Expiration возвращает время жизни заказа на момент now
+func (p Policy) Expiration(order *model.Order, now time.Time) time.Duration {
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	if p.CompletedTTL > 0 && isCompleted(order.Status) {
+		return p.CompletedTTL
+	}
+
+	if order.DateCreated.IsZero() {
+		return ttl
+	}
+
+	age := now.Sub(order.DateCreated)
+	switch {
+	case p.RecentTTL > 0 && age <= p.RecentAge:
+		return p.RecentTTL
+	case p.OldTTL > 0 && p.OldAge > 0 && age > p.OldAge:
+		return p.OldTTL
+	}
+
+	return ttl
+}
+
+// Cacheable сообщает, что заказ можно кешировать. size - длина его JSON
+func (p Policy) Cacheable(order *model.Order, size int) bool {
+	if p.MaxItems > 0 && len(order.Items) > p.MaxItems {
+		return false
+	}
+	if p.MaxBytes > 0 && size > p.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// isCompleted сообщает, что статус заказа больше не меняется, кроме возврата
+func isCompleted(status model.OrderStatus) bool {
+	switch status {
+	case model.StatusDelivered, model.StatusCancelled, model.StatusReturned:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func TestPolicy_Expiration(t *testing.T) {
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	policy := Policy{
+		TTL:          24 * time.Hour,
+		RecentTTL:    72 * time.Hour,
+		RecentAge:    48 * time.Hour,
+		OldTTL:       time.Hour,
+		OldAge:       30 * 24 * time.Hour,
+		CompletedTTL: 10 * time.Minute,
+	}
+
+	tests := []struct {
+		name  string
+		order model.Order
+		want  time.Duration
+	}{
+		{"recent", model.Order{DateCreated: now.Add(-time.Hour), Status: model.StatusPaid}, 72 * time.Hour},
+		{"regular", model.Order{DateCreated: now.Add(-7 * 24 * time.Hour), Status: model.StatusShipped}, 24 * time.Hour},
+		{"old", model.Order{DateCreated: now.Add(-60 * 24 * time.Hour), Status: model.StatusShipped}, time.Hour},
+		{"completed wins over age", model.Order{DateCreated: now.Add(-time.Hour), Status: model.StatusDelivered}, 10 * time.Minute},
+		{"unknown date", model.Order{Status: model.StatusCreated}, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.Expiration(&tt.order, now))
+		})
+	}
+
+	assert.Equal(t, DefaultTTL, Policy{}.Expiration(&model.Order{}, now))
+}
+
+func TestPolicy_Cacheable(t *testing.T) {
+	order := &model.Order{Items: make([]model.Item, 3)}
+
+	assert.True(t, Policy{}.Cacheable(order, 1<<20))
+	assert.True(t, Policy{MaxItems: 3, MaxBytes: 100}.Cacheable(order, 100))
+	assert.False(t, Policy{MaxItems: 2}.Cacheable(order, 100))
+	assert.False(t, Policy{MaxBytes: 99}.Cacheable(order, 100))
+}
@@ -0,0 +1,21 @@
+package cache
+
+import "time"
+
+// PreloadOptions - параметры прогрева кеша из БД. Нулевые значения
+// заменяются значениями по умолчанию реализации
+type PreloadOptions struct {
+	// Сколько заказов читается одним запросом и пишется одним пайплайном
+	BatchSize int
+	// Сколько пакетов загружается параллельно
+	Concurrency int
+	// Прогреть только Limit самых свежих заказов; 0 - все
+	Limit int
+	// Окно date_created: [CreatedFrom, CreatedTo). Нулевая граница не ограничивает
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Прогревать только пустой кеш. Прерванный прогрев продолжается в любом случае
+	IfEmpty bool
+	// Как часто сообщать о прогрессе
+	ProgressInterval time.Duration
+}
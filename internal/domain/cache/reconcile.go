@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// ReconcileOptions - параметры сверки кеша с БД
+type ReconcileOptions struct {
+	// Сколько ключей сверяется за один проход SCAN
+	BatchSize int
+	// Только отчёт, без исправлений
+	DryRun bool
+}
+
+// ReconcileReport - итог сверки кеша с БД
+type ReconcileReport struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	DryRun    bool          `json:"dry_run"`
+	// Сколько ключей order:* проверено
+	Scanned int `json:"scanned"`
+	// Совпали с БД
+	Matched int `json:"matched"`
+	// Отличались от БД и перезаписаны заказом из БД
+	Repaired int `json:"repaired"`
+	// Заказов нет в БД, записи удалены
+	Orphans int `json:"orphans"`
+	// Отметки об отсутствии заказа, который есть в БД, удалены
+	StaleMarkers int `json:"stale_markers"`
+	// Записи, которые не удалось разобрать, удалены
+	Corrupted int `json:"corrupted"`
+	// Заказы, которые политика больше не кеширует, удалены
+	Uncacheable int `json:"uncacheable"`
+	// Записи, изменившиеся во время сверки: они не трогаются
+	Skipped int `json:"skipped"`
+	// order_uid исправленных записей, не больше maxReportedUIDs
+	Mismatched []string `json:"mismatched,omitempty"`
+}
+
+// Fixed возвращает число исправленных или удалённых записей
+func (r *ReconcileReport) Fixed() int {
+	return r.Repaired + r.Orphans + r.StaleMarkers + r.Corrupted + r.Uncacheable
+}
+
+// Reconciler сверяет кеш с БД и исправляет расхождения
+type Reconciler interface {
+	Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error)
+}
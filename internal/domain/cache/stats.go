@@ -0,0 +1,23 @@
+package cache
+
+// TierStats - статистика попаданий одного уровня кеша
+type TierStats struct {
+	Tier    string  `json:"tier"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// NewTierStats считает долю попаданий по счётчикам уровня
+func NewTierStats(tier string, hits, misses uint64) TierStats {
+	stats := TierStats{Tier: tier, Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		stats.HitRate = float64(hits) / float64(total)
+	}
+	return stats
+}
+
+// StatsReporter реализуют кеши, которые считают попадания по уровням
+type StatsReporter interface {
+	Stats() []TierStats
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// EventType - вид изменения заказа в журнале order_events
+type EventType string
+
+const (
+	// EventCreated - заказ сохранён впервые
+	EventCreated EventType = "created"
+	// EventUpdated - содержимое заказа перезаписано по политике конфликтов
+	EventUpdated EventType = "updated"
+	// EventStatusChanged - заказ переведён в другой статус
+	EventStatusChanged EventType = "status_changed"
+)
+
+// Типы источников изменений
+const (
+	SourceKafka   = "kafka"
+	SourceHTTP    = "http"
+	SourceUnknown = "unknown"
+)
+
+// Source описывает, откуда пришло изменение: для Kafka ID - позиция
+// сообщения в виде topic[partition]@offset, для HTTP - ID запроса
+type Source struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+}
+
+// Event - запись журнала изменений заказа
+type Event struct {
+	ID        int64           `json:"id"`
+	OrderUID  string          `json:"order_uid"`
+	Type      EventType       `json:"event_type"`
+	Source    Source          `json:"source"`
+	OldValue  json.RawMessage `json:"old_value,omitempty"`
+	NewValue  json.RawMessage `json:"new_value,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type sourceKey struct{}
+
+type batchSourcesKey struct{}
+
+// WithSource сохраняет в контексте источник изменений, которые
+// будут сделаны в рамках этого контекста
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom возвращает источник изменений из контекста
+func SourceFrom(ctx context.Context) Source {
+	if source, ok := ctx.Value(sourceKey{}).(Source); ok {
+		return source
+	}
+	return Source{Type: SourceUnknown}
+}
+
+// WithBatchSources сохраняет источники сообщений пакета в порядке,
+// в котором они передаются в SaveOrders
+func WithBatchSources(ctx context.Context, sources []Source) context.Context {
+	return context.WithValue(ctx, batchSourcesKey{}, sources)
+}
+
+// BatchSourceFrom возвращает источник i-го сообщения пакета, а если
+// источники пакета не заданы - общий источник контекста
+func BatchSourceFrom(ctx context.Context, i int) Source {
+	if sources, ok := ctx.Value(batchSourcesKey{}).([]Source); ok && i < len(sources) {
+		return sources[i]
+	}
+	return SourceFrom(ctx)
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// ErrInvalidCursor возвращается, если курсор страницы не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListFilter - условия выборки для постраничного списка заказов.
+// Пустые поля не участвуют в фильтрации
+type ListFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Currency        string
+	Brand           string    // заказ содержит хотя бы один товар этого бренда
+	CreatedFrom     time.Time // date_created >= CreatedFrom
+	CreatedTo       time.Time // date_created < CreatedTo
+	After           *Cursor   // начать со следующего за курсором заказа
+	Limit           int
+}
+
+// Cursor указывает на последний заказ страницы. Заказы упорядочены
+// по убыванию date_created, при равенстве - по убыванию order_uid
+type Cursor struct {
+	DateCreated time.Time `json:"date_created"`
+	OrderUID    string    `json:"order_uid"`
+}
+
+// OrderPage - страница списка заказов. Next равен nil на последней странице
+type OrderPage struct {
+	Orders []*model.Order
+	Next   *Cursor
+}
+
+// Encode превращает курсор в непрозрачный токен для клиента
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает токен, полученный от Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if c.OrderUID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
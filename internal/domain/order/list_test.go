@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	// Postgres хранит date_created с точностью до микросекунд: курсор
+	// не должен их терять, иначе следующая страница повторит или пропустит заказы
+	cursor := Cursor{
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 123456000, time.UTC),
+		OrderUID:    "b563feb7b2b84b556test",
+	}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, cursor, *decoded)
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "%%%"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("order"))},
+		{"bad date", base64.RawURLEncoding.EncodeToString([]byte(`{"date_created":"yesterday","order_uid":"a"}`))},
+		{"no order uid", base64.RawURLEncoding.EncodeToString([]byte(`{"date_created":"2021-11-26T06:22:19Z"}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCursor(tt.token)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
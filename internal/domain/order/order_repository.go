@@ -2,12 +2,35 @@ package domain
 
 import (
 	"context"
+	"errors"
 
 	model "github.com/sayhellolexa/order-service/internal/model"
 )
 
+// ErrInvalidOrder означает, что сообщение с заказом не удалось разобрать
+// или оно не прошло валидацию: повторное сохранение не поможет
+var ErrInvalidOrder = errors.New("invalid order")
+
+// ErrOrderConflict означает, что заказ с таким order_uid уже сохранён
+// с другим содержимым, а политика конфликтов запрещает его перезапись
+var ErrOrderConflict = errors.New("order already exists with different content")
+
+// ErrOrderNotFound возвращается, если заказа с таким order_uid нет
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrInvalidTransition возвращается, если переход между статусами запрещён
+var ErrInvalidTransition = errors.New("invalid status transition")
+
 // Абстракция, которая определяет методы для работы с заказами
 type Repository interface {
 	GetOrderById(ctx context.Context, id string) (*model.Order, error)
 	SaveOrder(ctx context.Context, message []byte) error
+	// SaveOrders сохраняет пакет сообщений и возвращает ошибку для каждого из них
+	SaveOrders(ctx context.Context, messages [][]byte) []error
+	// ListOrders возвращает страницу заказов, подходящих под фильтр
+	ListOrders(ctx context.Context, filter ListFilter) (*OrderPage, error)
+	// UpdateStatus переводит заказ в новый статус и возвращает обновлённый заказ
+	UpdateStatus(ctx context.Context, orderUID string, status model.OrderStatus) (*model.Order, error)
+	// GetOrderHistory возвращает журнал изменений заказа от старых записей к новым
+	GetOrderHistory(ctx context.Context, orderUID string) ([]Event, error)
 }
\ No newline at end of file
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Сколько по умолчанию может длиться одна проверка
+const DefaultTimeout = 2 * time.Second
+
+// Check проверяет одну зависимость. nil означает, что она доступна
+type Check func(ctx context.Context) error
+
+// Status - итог проверки
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// Result - итог одной проверки
+type Result struct {
+	Status    Status  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report - итог всех проверок. Info содержит сведения, которые
+// не влияют на готовность, например время последнего обработанного сообщения
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+	Info   map[string]any    `json:"info,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type namedInfo struct {
+	name string
+	info func() any
+}
+
+// Checker выполняет проверки зависимостей для readiness-эндпоинта
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	info    []namedInfo
+	timeout time.Duration
+}
+
+// NewChecker создаёт набор проверок, каждая из которых ограничена timeout
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Add добавляет проверку зависимости name
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// AddInfo добавляет в отчёт сведения name, не влияющие на готовность
+func (c *Checker) AddInfo(name string, info func() any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.info = append(c.info, namedInfo{name: name, info: info})
+}
+
+// Run выполняет все проверки параллельно. Сервис готов, только если
+// успешны все проверки
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := c.checks
+	info := c.info
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, nc.check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, nc := range checks {
+		report.Checks[nc.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	if len(info) > 0 {
+		report.Info = make(map[string]any, len(info))
+		for _, ni := range info {
+			report.Info[ni.name] = ni.info()
+		}
+	}
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ReadyHandler отдаёт отчёт проверок: 200, если сервис готов принимать
+// трафик, и 503, если нет
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, report)
+	})
+}
+
+// LiveHandler отвечает 200, пока процесс жив и обслуживает запросы
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]Status{"status": StatusOK})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,105 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// BatchHandler обрабатывает пакет сообщений целиком. Возвращает ошибку
+// для каждого сообщения: неудачные сообщения дальше обрабатываются
+// по одному через HandleMessage с повторами и DLQ
+type BatchHandler interface {
+	Handler
+	HandleBatch(ctx context.Context, messages [][]byte) []error
+}
+
+// consumeBatches читает сообщения пакетами, пока не будет отменён ctx.
+// Недособранный к остановке пакет не обрабатывается и будет прочитан
+// заново, как и сообщения партиций, отозванных ребалансировкой
+func (c *Consumer) consumeBatches(ctx, handleCtx context.Context) {
+	c.pending = make([]*kafka.Message, 0, c.batchSize)
+	var deadline time.Time
+
+	for ctx.Err() == nil {
+		timeout := pollTimeout
+		if len(c.pending) > 0 {
+			timeout = min(timeout, time.Until(deadline))
+		}
+
+		if timeout > 0 {
+			if kafkaMsg := c.read(timeout); kafkaMsg != nil {
+				if len(c.pending) == 0 {
+					deadline = time.Now().Add(c.batchWait)
+				}
+				c.pending = append(c.pending, kafkaMsg)
+			}
+		}
+
+		if len(c.pending) >= c.batchSize || (len(c.pending) > 0 && !time.Now().Before(deadline)) {
+			c.processBatch(ctx, handleCtx, c.pending)
+			c.pending = c.pending[:0]
+		}
+	}
+}
+
+// dropRevoked убирает из недособранного пакета сообщения отозванных
+// партиций: их оффсеты не сохранены, и новый владелец прочитает их сам
+func (c *Consumer) dropRevoked(partitions []kafka.TopicPartition) {
+	revoked := make(map[partitionKey]bool, len(partitions))
+	for _, tp := range partitions {
+		revoked[keyOf(tp)] = true
+	}
+
+	kept := c.pending[:0]
+	for _, kafkaMsg := range c.pending {
+		if !revoked[keyOf(kafkaMsg.TopicPartition)] {
+			kept = append(kept, kafkaMsg)
+		}
+	}
+	clear(c.pending[len(kept):])
+	c.pending = kept
+}
+
+// processBatch передаёт пакет обработчику, дообрабатывает неудачные
+// сообщения по одному и сохраняет оффсеты по порядку внутри каждой партиции
+func (c *Consumer) processBatch(ctx, handleCtx context.Context, batch []*kafka.Message) {
+	handleCtx, span := startBatchSpan(handleCtx, batch)
+	defer span.End()
+
+	values := make([][]byte, len(batch))
+	for i, kafkaMsg := range batch {
+		values[i] = kafkaMsg.Value
+	}
+
+	errs := c.handler.(BatchHandler).HandleBatch(withBatch(handleCtx, batch), values)
+
+	// Партиции, в которых сообщение осталось необработанным: следующие
+	// за ним оффсеты сохранять нельзя, иначе оно потеряется
+	blocked := make(map[partitionKey]bool)
+
+	for i, kafkaMsg := range batch {
+		key := keyOf(kafkaMsg.TopicPartition)
+		if blocked[key] {
+			continue
+		}
+
+		var err error
+		if i < len(errs) {
+			err = errs[i]
+		}
+
+		attempts := 1
+		if err != nil {
+			attempts, err = c.retry(ctx, handleCtx, kafkaMsg, attempts, err)
+		}
+
+		switch c.settle(ctx, kafkaMsg, attempts, err) {
+		case outcomeHandled:
+			c.store(kafkaMsg.TopicPartition)
+		case outcomeUnfinished:
+			blocked[key] = true
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sayhellolexa/order-service/internal/kafka/retry"
+)
+
+// fakeClient запоминает сохранённые оффсеты и число коммитов
+type fakeClient struct {
+	client
+	stored  []kafka.TopicPartition
+	commits int
+}
+
+func (f *fakeClient) Commit() ([]kafka.TopicPartition, error) {
+	f.commits++
+	return nil, nil
+}
+
+func (f *fakeClient) StoreOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	f.stored = append(f.stored, offsets...)
+	return offsets, nil
+}
+
+// fakeBatchHandler отвечает на пакет заданными ошибками, а при
+// обработке по одному - ошибкой из retries для значения сообщения
+type fakeBatchHandler struct {
+	batchErrs []error
+	retries   map[string]error
+	handled   []string
+}
+
+func (h *fakeBatchHandler) HandleBatch(_ context.Context, messages [][]byte) []error {
+	return h.batchErrs
+}
+
+func (h *fakeBatchHandler) HandleMessage(_ context.Context, message []byte, _ kafka.Offset) error {
+	h.handled = append(h.handled, string(message))
+	return h.retries[string(message)]
+}
+
+func TestConsumer_ProcessBatchPartialFailure(t *testing.T) {
+	topic := "orders"
+	message := func(partition int32, offset kafka.Offset) *kafka.Message {
+		return &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset},
+			Value:          []byte(fmt.Sprintf("%d@%d", partition, offset)),
+		}
+	}
+	stored := func(partition int32, offset kafka.Offset) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset}
+	}
+
+	newConsumer := func(handler *fakeBatchHandler) (*Consumer, *fakeClient) {
+		client := &fakeClient{}
+		policies := retry.DefaultPolicies()
+		policies[retry.ClassTransient] = retry.Policy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+		return &Consumer{consumer: client, handler: handler, policies: policies, logger: slog.Default()}, client
+	}
+
+	t.Run("Failed messages retried one by one", func(t *testing.T) {
+		batch := []*kafka.Message{message(0, 10), message(0, 11), message(1, 5), message(1, 6)}
+		handler := &fakeBatchHandler{
+			batchErrs: []error{nil, errors.New("timeout"), retry.Permanent(errors.New("invalid order")), nil},
+		}
+		c, client := newConsumer(handler)
+
+		c.processBatch(context.Background(), context.Background(), batch)
+
+		// Временная ошибка прошла повтором, постоянная без DLQ пропущена
+		// и не задерживает следующие сообщения своей партиции
+		assert.Equal(t, []string{string(batch[1].Value)}, handler.handled)
+		assert.Equal(t, []kafka.TopicPartition{stored(0, 11), stored(0, 12), stored(1, 7)}, client.stored)
+		assert.False(t, c.LastHandled().IsZero())
+	})
+
+	t.Run("Unfinished message blocks its partition", func(t *testing.T) {
+		batch := []*kafka.Message{message(0, 10), message(0, 11), message(1, 5)}
+		handler := &fakeBatchHandler{batchErrs: []error{errors.New("timeout"), nil, nil}}
+		c, client := newConsumer(handler)
+		c.policies[retry.ClassTransient] = retry.Policy{MaxAttempts: 2, InitialBackoff: time.Minute}
+
+		// Остановка прерывает повторы: первое сообщение будет прочитано
+		// снова, поэтому оффсеты его партиции дальше не сохраняются
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c.processBatch(ctx, context.Background(), batch)
+
+		assert.Empty(t, handler.handled)
+		assert.Equal(t, []kafka.TopicPartition{stored(1, 6)}, client.stored)
+	})
+}
+
+func TestConsumer_RebalanceDropsRevokedFromBatch(t *testing.T) {
+	topic := "orders"
+	message := func(partition int32, offset kafka.Offset) *kafka.Message {
+		return &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset}}
+	}
+
+	client := &fakeClient{}
+	c := &Consumer{consumer: client, batchSize: 10, logger: slog.Default()}
+	c.pending = []*kafka.Message{message(0, 10), message(1, 5), message(0, 11), message(1, 6)}
+
+	err := c.rebalance(nil, kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{{Topic: &topic, Partition: 0}}})
+
+	// Сообщения отозванной партиции не обрабатываются здесь: их прочитает
+	// новый владелец с последнего закоммиченного оффсета
+	assert.NoError(t, err)
+	assert.Equal(t, []*kafka.Message{message(1, 5), message(1, 6)}, c.pending)
+	assert.Equal(t, 1, client.commits)
+	assert.Empty(t, client.stored)
+}
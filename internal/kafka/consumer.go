@@ -2,28 +2,130 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/sayhellolexa/order-service/internal/kafka/retry"
+	"github.com/sayhellolexa/order-service/internal/metrics"
+	"github.com/sayhellolexa/order-service/internal/tracing"
 )
 
 const (
 	sessionTimeout = 10000
-	noTimeout      = -1
+	// Как долго ждать сообщение, прежде чем снова проверить, не пора ли остановиться
+	pollTimeout = 100 * time.Millisecond
+
+	// Пауза перед повторной публикацией сообщения, которое не удалось переложить в DLQ
+	deadLetterRetryDelay = time.Second
+
+	// Сколько ждать ответа брокера при проверке связи, если у ctx нет дедлайна
+	pingTimeout = 2 * time.Second
 )
 
 type Handler interface {
 	HandleMessage(ctx context.Context, message []byte, offset kafka.Offset) error
 }
 
+// client - методы kafka.Consumer, через которые работает Consumer
+type client interface {
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	GetWatermarkOffsets(topic string, partition int32) (low, high int64, err error)
+	StoreOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	Commit() ([]kafka.TopicPartition, error)
+	GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error)
+	Close() error
+}
+
 type Consumer struct {
-	consumer *kafka.Consumer
-	handler  Handler
-	stop     bool
+	consumer   client
+	topic      string
+	handler    Handler
+	deadLetter *DeadLetterQueue
+	policies   map[retry.Class]retry.Policy
+	pool       *workerPool
+	workers    int
+	batchSize  int
+	batchWait  time.Duration
+	// pending - недособранный пакет. С ним работает только горутина Start:
+	// колбэк ребалансировки вызывается внутри чтения
+	pending []*kafka.Message
+	// shutdownTimeout - сколько обработчики могут работать после остановки
+	shutdownTimeout time.Duration
+	logger          *slog.Logger
+	// lastHandled - время последнего успешно обработанного сообщения в UnixNano
+	lastHandled atomic.Int64
+	// closeMu защищает closed и stopHandlers. active считает обращения
+	// к консьюмеру, которых дожидается закрытие
+	closeMu      sync.Mutex
+	closed       bool
+	stopHandlers context.CancelFunc
+	active       sync.WaitGroup
+	closeOnce    sync.Once
+}
+
+// Option настраивает необязательные параметры консьюмера
+type Option func(*Consumer)
+
+// WithRetryPolicy задаёт политику повторов для класса ошибок
+func WithRetryPolicy(class retry.Class, policy retry.Policy) Option {
+	return func(c *Consumer) {
+		c.policies[class] = policy
+	}
+}
+
+// WithDeadLetterQueue включает перекладывание сообщений,
+// которые не удалось обработать, в dead-letter топик
+func WithDeadLetterQueue(dlq *DeadLetterQueue) Option {
+	return func(c *Consumer) {
+		c.deadLetter = dlq
+	}
+}
+
+// WithWorkers включает параллельную обработку сообщений в n воркерах.
+// Сообщения с одинаковым ключом (order_uid), а без ключа - из одной партиции,
+// всегда попадают в один воркер, поэтому их порядок сохраняется
+func WithWorkers(n int) Option {
+	return func(c *Consumer) {
+		c.workers = n
+	}
 }
 
-func NewConsumer(address []string, consumerGroup string, topic string, handler Handler) (*Consumer, error) {
+// WithBatch включает пакетный режим: сообщения копятся, пока их не наберётся
+// size или с первого не пройдёт wait, и передаются обработчику одним пакетом.
+// Обработчик должен реализовывать BatchHandler
+func WithBatch(size int, wait time.Duration) Option {
+	return func(c *Consumer) {
+		c.batchSize = size
+		c.batchWait = wait
+	}
+}
+
+// WithShutdownTimeout ограничивает время, которое текущие сообщения
+// обрабатываются после остановки. По истечении контекст обработчика
+// отменяется. Без опции обработка не ограничена
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(c *Consumer) {
+		c.shutdownTimeout = d
+	}
+}
+
+// WithLogger задаёт логгер консьюмера. По умолчанию используется slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Consumer) {
+		c.logger = logger
+	}
+}
+
+func NewConsumer(address []string, consumerGroup string, topic string, handler Handler, opts ...Option) (*Consumer, error) {
 	conf := &kafka.ConfigMap{
 		"bootstrap.servers": strings.Join(address, ","),
 		"group.id": consumerGroup,
@@ -38,43 +140,405 @@ func NewConsumer(address []string, consumerGroup string, topic string, handler H
 		return nil, fmt.Errorf("error with new consumer: %w", err)
 	}
 
-	if err := c.Subscribe(topic, nil); err != nil {
+	consumer := &Consumer{consumer: c, topic: topic, handler: handler, policies: retry.DefaultPolicies(), logger: slog.Default()}
+	for _, opt := range opts {
+		opt(consumer)
+	}
+
+	if consumer.batchSize > 1 {
+		var err error
+		if _, ok := handler.(BatchHandler); !ok {
+			err = errors.New("error with batch mode: handler does not implement BatchHandler")
+		} else if consumer.workers > 1 {
+			err = errors.New("error with batch mode: it cannot be combined with workers")
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if consumer.workers > 1 {
+		consumer.pool = newWorkerPool(consumer, consumer.workers)
+	}
+
+	if err := c.Subscribe(topic, consumer.rebalance); err != nil {
 		return nil, fmt.Errorf("error with subscribe: %w", err)
 	}
 
-	return &Consumer{consumer: c, handler: handler}, nil
+	return consumer, nil
 }
 
-func (c *Consumer) Start() {
-	ctx := context.Background()
+// Start читает сообщения, пока не будет отменён ctx. Отмена не прерывает
+// обработку текущих сообщений: они доводятся до конца (не дольше
+// WithShutdownTimeout), после чего сохранённые оффсеты синхронно
+// коммитятся и консьюмер закрывается. Start возвращается только
+// после закрытия консьюмера, в том числе через Close
+func (c *Consumer) Start(ctx context.Context) error {
+	handleCtx, cancel := c.handleContext(ctx)
+	defer cancel()
 
-	for {
-		if c.stop {
-			break
-		}
+	c.closeMu.Lock()
+	c.stopHandlers = cancel
+	if c.closed {
+		cancel()
+	}
+	c.closeMu.Unlock()
 
-		kafkaMsg, err := c.consumer.ReadMessage(noTimeout)
-		if err != nil {
-			fmt.Printf("error with read message: %v\n", err)
-			continue
-		}
+	if c.pool != nil {
+		c.pool.start(ctx, handleCtx)
+	}
+
+	if c.batchSize > 1 {
+		c.consumeBatches(ctx, handleCtx)
+		return c.close()
+	}
+
+	for ctx.Err() == nil {
+		kafkaMsg := c.read(pollTimeout)
 		if kafkaMsg == nil {
 			continue
 		}
 
-		if err := c.handler.HandleMessage(ctx, kafkaMsg.Value, kafkaMsg.TopicPartition.Offset); err != nil {
-			fmt.Printf("error with handle message: %v\n", err)
+		if c.pool != nil {
+			c.pool.dispatch(kafkaMsg)
 			continue
 		}
 
-		if _, err := c.consumer.StoreMessage(kafkaMsg); err != nil {
-			fmt.Printf("error with store message: %v\n", err)
-			continue
+		if c.process(ctx, handleCtx, kafkaMsg) == outcomeHandled {
+			c.store(kafkaMsg.TopicPartition)
+		}
+	}
+
+	if c.pool != nil {
+		c.pool.stop()
+	}
+
+	return c.close()
+}
+
+// handleContext возвращает контекст обработчиков. Он не отменяется вместе
+// с ctx, чтобы не оборвать транзакцию в Postgres на середине, но после
+// остановки живёт не дольше shutdownTimeout: обработчик не должен пережить
+// закрытие пулов и клиентов, с которыми работает
+func (c *Consumer) handleContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	handleCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if c.shutdownTimeout <= 0 {
+		return handleCtx, cancel
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-handleCtx.Done():
+			return
+		}
+
+		timer := time.NewTimer(c.shutdownTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			cancel()
+		case <-handleCtx.Done():
+		}
+	}()
+
+	return handleCtx, cancel
+}
+
+// read ждёт очередное сообщение не дольше timeout. Возвращает nil,
+// если сообщений нет или чтение завершилось ошибкой
+func (c *Consumer) read(timeout time.Duration) *kafka.Message {
+	if !c.acquire() {
+		// Консьюмер закрыт по истечении времени на остановку
+		time.Sleep(timeout)
+		return nil
+	}
+	defer c.release()
+
+	kafkaMsg, err := c.consumer.ReadMessage(timeout)
+	if err != nil {
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrTimedOut {
+			c.logger.Error("Error reading message", "error", err)
+		}
+		return nil
+	}
+
+	c.observe(kafkaMsg.TopicPartition)
+	return kafkaMsg
+}
+
+// observe учитывает прочитанное сообщение и отставание его партиции.
+// Верхняя граница берётся из последнего ответа брокера без лишнего запроса
+func (c *Consumer) observe(tp kafka.TopicPartition) {
+	topic := *tp.Topic
+	metrics.KafkaMessages.WithLabelValues(topic).Inc()
+
+	_, high, err := c.consumer.GetWatermarkOffsets(topic, tp.Partition)
+	if err != nil || high < 0 {
+		return
+	}
+
+	lag := max(high-int64(tp.Offset)-1, 0)
+	metrics.KafkaConsumerLag.WithLabelValues(topic, strconv.Itoa(int(tp.Partition))).Set(float64(lag))
+}
+
+// outcome - итог обработки сообщения
+type outcome int
+
+const (
+	// outcomeHandled - сообщение обработано или переложено в DLQ, оффсет можно сохранять
+	outcomeHandled outcome = iota
+	// outcomeSkipped - обработать не удалось, а DLQ не настроен: сообщение
+	// не сохраняется, но и не задерживает следующие
+	outcomeSkipped
+	// outcomeUnfinished - обработка прервана остановкой, сообщение будет прочитано снова
+	outcomeUnfinished
+)
+
+// process обрабатывает одно сообщение и при неудаче перекладывает его в DLQ
+func (c *Consumer) process(ctx, handleCtx context.Context, kafkaMsg *kafka.Message) outcome {
+	handleCtx, span := startProcessSpan(handleCtx, kafkaMsg)
+
+	attempts, err := c.handle(ctx, handleCtx, kafkaMsg)
+	span.SetAttributes(attribute.Int("messaging.attempts", attempts))
+
+	result := c.settle(ctx, kafkaMsg, attempts, err)
+	tracing.End(span, err)
+	return result
+}
+
+// settle подводит итог обработки: сообщение, которое так и не удалось
+// обработать за attempts попыток, перекладывается в DLQ
+func (c *Consumer) settle(ctx context.Context, kafkaMsg *kafka.Message, attempts int, err error) outcome {
+	if err == nil {
+		c.lastHandled.Store(time.Now().UnixNano())
+		return outcomeHandled
+	}
+
+	log := c.messageLogger(kafkaMsg.TopicPartition)
+	if ctx.Err() != nil {
+		// Повторы прерваны остановкой: сообщение будет прочитано снова после рестарта
+		log.Warn("Message handling interrupted by shutdown", "error", err)
+		return outcomeUnfinished
+	}
+
+	log.Error("Error handling message", "attempts", attempts, "error", err)
+
+	if c.deadLetter == nil {
+		return outcomeSkipped
+	}
+
+	// Сообщение нельзя терять: публикуем его, пока DLQ не станет доступен
+	for {
+		dlqErr := c.deadLetter.Publish(kafkaMsg, err, attempts)
+		if dlqErr == nil {
+			break
+		}
+		log.Error("Error moving message to dead-letter topic", "error", dlqErr)
+
+		select {
+		case <-ctx.Done():
+			// Оффсет не сохранён, после рестарта сообщение будет прочитано заново
+			return outcomeUnfinished
+		case <-time.After(deadLetterRetryDelay):
+		}
+	}
+
+	metrics.KafkaDeadLetters.WithLabelValues(*kafkaMsg.TopicPartition.Topic).Inc()
+	log.Warn("Message moved to dead-letter topic", "dead_letter_topic", c.deadLetter.Topic())
+	return outcomeHandled
+}
+
+// store сохраняет оффсет, следующий за обработанным сообщением,
+// для ближайшего коммита
+func (c *Consumer) store(tp kafka.TopicPartition) {
+	if !c.acquire() {
+		// Оффсет не сохранён, после рестарта сообщение будет прочитано заново
+		return
+	}
+	defer c.release()
+
+	tp.Offset++
+	if _, err := c.consumer.StoreOffsets([]kafka.TopicPartition{tp}); err != nil {
+		c.messageLogger(tp).Error("Error storing offset", "error", err)
+	}
+}
+
+// rebalance перед отзывом партиций дожидается сообщений, которые
+// ещё обрабатываются воркерами, или убирает их из недособранного пакета
+// и коммитит сохранённые оффсеты
+func (c *Consumer) rebalance(_ *kafka.Consumer, event kafka.Event) error {
+	revoked, ok := event.(kafka.RevokedPartitions)
+	if !ok {
+		return nil
+	}
+
+	// Отставание отозванных партиций считает теперь другой консьюмер
+	for _, tp := range revoked.Partitions {
+		metrics.KafkaConsumerLag.DeleteLabelValues(*tp.Topic, strconv.Itoa(int(tp.Partition)))
+	}
+
+	switch {
+	case c.pool != nil:
+		c.pool.drain()
+		c.pool.tracker.forget(revoked.Partitions)
+	case c.batchSize > 1:
+		c.dropRevoked(revoked.Partitions)
+	default:
+		return nil
+	}
+
+	if _, err := c.consumer.Commit(); err != nil && !isNoOffset(err) {
+		c.logger.Error("Error committing offsets on rebalance", "error", err)
+	}
+
+	return nil
+}
+
+// handle обрабатывает сообщение, повторяя попытки согласно политике
+// для класса полученной ошибки. Возвращает число сделанных попыток
+func (c *Consumer) handle(ctx, handleCtx context.Context, kafkaMsg *kafka.Message) (int, error) {
+	err := c.handler.HandleMessage(withMessage(handleCtx, kafkaMsg.TopicPartition), kafkaMsg.Value, kafkaMsg.TopicPartition.Offset)
+	return c.retry(ctx, handleCtx, kafkaMsg, 1, err)
+}
+
+// retry продолжает обработку сообщения после attempt попыток, последняя
+// из которых завершилась ошибкой err
+func (c *Consumer) retry(ctx, handleCtx context.Context, kafkaMsg *kafka.Message, attempt int, err error) (int, error) {
+	for ; err != nil; attempt++ {
+		class := retry.Classify(err)
+		metrics.KafkaHandlerFailures.WithLabelValues(*kafkaMsg.TopicPartition.Topic, class.String()).Inc()
+
+		policy := c.policies[class]
+		if attempt >= policy.MaxAttempts {
+			return attempt, err
+		}
+
+		delay := policy.Backoff(attempt)
+		c.messageLogger(kafkaMsg.TopicPartition).Warn("Error handling message, retrying",
+			"class", class.String(), "attempt", attempt, "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
 		}
+
+		err = c.handler.HandleMessage(withMessage(handleCtx, kafkaMsg.TopicPartition), kafkaMsg.Value, kafkaMsg.TopicPartition.Offset)
+	}
+
+	return attempt, nil
+}
+
+// Topic возвращает топик, на который подписан консьюмер
+func (c *Consumer) Topic() string {
+	return c.topic
+}
+
+// LastHandled возвращает время последнего успешно обработанного сообщения.
+// Нулевое время означает, что сообщений ещё не было
+func (c *Consumer) LastHandled() time.Time {
+	nanos := c.lastHandled.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Ping проверяет связь с брокером, запрашивая метаданные топика консьюмера
+func (c *Consumer) Ping(ctx context.Context) error {
+	timeout := pingTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if timeout <= 0 {
+		return ctx.Err()
+	}
+
+	if !c.acquire() {
+		return errors.New("error with ping: consumer is closed")
 	}
+	defer c.release()
+
+	if _, err := c.consumer.GetMetadata(&c.topic, false, int(timeout.Milliseconds())); err != nil {
+		return fmt.Errorf("error with get metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Close отменяет контекст обработчиков и закрывает консьюмер, не дожидаясь
+// завершения Start. Нужен, когда остановка не уложилась в отведённое время:
+// консьюмер должен быть закрыт до того, как закроются ресурсы его обработчиков.
+// Повторный вызов ничего не делает
+func (c *Consumer) Close() error {
+	c.closeMu.Lock()
+	if c.stopHandlers != nil {
+		c.stopHandlers()
+	}
+	c.closeMu.Unlock()
+
+	return c.close()
+}
+
+// acquire отмечает обращение к консьюмеру. Возвращает false, если он
+// уже закрывается. После успешного вызова нужно вызвать release
+func (c *Consumer) acquire() bool {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.active.Add(1)
+	return true
+}
+
+func (c *Consumer) release() {
+	c.active.Done()
+}
+
+// close дожидается текущих обращений к консьюмеру, синхронно коммитит
+// сохранённые оффсеты и закрывает его. Одновременный вызов ждёт, пока
+// консьюмер не будет закрыт, и возвращает nil
+func (c *Consumer) close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.closeMu.Lock()
+		c.closed = true
+		c.closeMu.Unlock()
+
+		// Обращение из колбэка ребалансировки внутри чтения уже не начнётся:
+		// acquire вернёт false, поэтому ожидание не зависнет
+		c.active.Wait()
+		err = c.closeClient()
+	})
+	return err
+}
+
+func (c *Consumer) closeClient() error {
+	var commitErr error
+	if _, err := c.consumer.Commit(); err != nil && !isNoOffset(err) {
+		commitErr = fmt.Errorf("error with commit offsets: %w", err)
+	}
+
+	if err := c.consumer.Close(); err != nil {
+		return errors.Join(commitErr, fmt.Errorf("error with close consumer: %w", err))
+	}
+
+	return commitErr
+}
+
+// messageLogger дополняет записи лога позицией сообщения
+func (c *Consumer) messageLogger(tp kafka.TopicPartition) *slog.Logger {
+	return c.logger.With(messageLogAttrs(tp)...)
 }
 
-func (c *Consumer) Stop() error {
-	c.stop = true
-	return c.consumer.Close()
+// isNoOffset сообщает, что коммитить нечего
+func isNoOffset(err error) bool {
+	var kafkaErr kafka.Error
+	return errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrNoOffset
 }
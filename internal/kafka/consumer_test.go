@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumer_HandleContextOutlivesStopUntilTimeout(t *testing.T) {
+	c := &Consumer{shutdownTimeout: 50 * time.Millisecond}
+
+	ctx, stop := context.WithCancel(context.Background())
+	handleCtx, cancel := c.handleContext(ctx)
+	defer cancel()
+
+	stop()
+
+	// Сразу после остановки текущая обработка продолжается
+	assert.NoError(t, handleCtx.Err())
+
+	select {
+	case <-handleCtx.Done():
+	case <-time.After(time.Second):
+		require.Fail(t, "handler context was not cancelled after shutdown timeout")
+	}
+}
+
+func TestConsumer_HandleContextWithoutTimeout(t *testing.T) {
+	c := &Consumer{}
+
+	ctx, stop := context.WithCancel(context.Background())
+	handleCtx, cancel := c.handleContext(ctx)
+
+	stop()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, handleCtx.Err())
+
+	cancel()
+	assert.ErrorIs(t, handleCtx.Err(), context.Canceled)
+}
+
+// readingClient блокирует чтение, пока не закрыт release
+type readingClient struct {
+	fakeClient
+	reading chan struct{}
+	release chan struct{}
+	closes  int
+}
+
+func (f *readingClient) ReadMessage(time.Duration) (*kafka.Message, error) {
+	close(f.reading)
+	<-f.release
+	return nil, kafka.NewError(kafka.ErrTimedOut, "timed out", false)
+}
+
+func (f *readingClient) Close() error {
+	f.closes++
+	return nil
+}
+
+func TestConsumer_CloseWaitsForRead(t *testing.T) {
+	client := &readingClient{reading: make(chan struct{}), release: make(chan struct{})}
+	c := &Consumer{consumer: client, logger: slog.Default()}
+
+	go c.read(pollTimeout)
+	<-client.reading
+
+	closed := make(chan error)
+	go func() {
+		closed <- c.Close()
+	}()
+
+	// Пока идёт чтение, librdkafka-консьюмер не закрывается
+	select {
+	case <-closed:
+		require.Fail(t, "consumer closed during read")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(client.release)
+	require.NoError(t, <-closed)
+	assert.Equal(t, 1, client.closes)
+
+	// После закрытия оффсеты не сохраняются, повторное закрытие ничего не делает
+	topic := "orders"
+	c.store(kafka.TopicPartition{Topic: &topic, Offset: 1})
+	assert.Empty(t, client.stored)
+	assert.NoError(t, c.Close())
+	assert.Equal(t, 1, client.closes)
+}
+
+func TestConsumer_CloseCancelsHandlers(t *testing.T) {
+	client := &readingClient{reading: make(chan struct{}), release: make(chan struct{})}
+	close(client.release)
+	c := &Consumer{consumer: client, logger: slog.Default()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handleCtx, stop := c.handleContext(ctx)
+	c.stopHandlers = stop
+
+	require.NoError(t, c.Close())
+	assert.ErrorIs(t, handleCtx.Err(), context.Canceled)
+}
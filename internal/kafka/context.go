@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	"github.com/sayhellolexa/order-service/internal/logger"
+)
+
+type messageKey struct{}
+
+type batchKey struct{}
+
+// withMessage сохраняет в контексте обработчика позицию обрабатываемого
+// сообщения и добавляет её ко всем записям логов обработчика
+func withMessage(ctx context.Context, tp kafka.TopicPartition) context.Context {
+	ctx = logger.With(ctx, messageLogAttrs(tp)...)
+	return context.WithValue(ctx, messageKey{}, tp)
+}
+
+// messageLogAttrs описывает позицию сообщения в атрибутах записи лога
+func messageLogAttrs(tp kafka.TopicPartition) []any {
+	var topic string
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return []any{"topic", topic, "partition", tp.Partition, "offset", int64(tp.Offset)}
+}
+
+// MessageFromContext возвращает позицию сообщения, которое обрабатывается
+// в рамках ctx в HandleMessage
+func MessageFromContext(ctx context.Context) (kafka.TopicPartition, bool) {
+	tp, ok := ctx.Value(messageKey{}).(kafka.TopicPartition)
+	return tp, ok
+}
+
+// withBatch сохраняет в контексте обработчика позиции сообщений пакета
+func withBatch(ctx context.Context, batch []*kafka.Message) context.Context {
+	positions := make([]kafka.TopicPartition, len(batch))
+	for i, kafkaMsg := range batch {
+		positions[i] = kafkaMsg.TopicPartition
+	}
+
+	first, last := batch[0].TopicPartition, batch[len(batch)-1].TopicPartition
+	ctx = logger.With(ctx, "batch_size", len(batch), "first_offset", int64(first.Offset), "last_offset", int64(last.Offset))
+
+	return context.WithValue(ctx, batchKey{}, positions)
+}
+
+// BatchFromContext возвращает позиции сообщений пакета, переданного
+// в HandleBatch, в том же порядке, что и сами сообщения
+func BatchFromContext(ctx context.Context) []kafka.TopicPartition {
+	positions, _ := ctx.Value(batchKey{}).([]kafka.TopicPartition)
+	return positions
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	"github.com/sayhellolexa/order-service/internal/kafka/retry"
+)
+
+// Заголовки, с которыми сообщение перекладывается в dead-letter топик
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	HeaderErrorClass        = "x-error-class"
+	HeaderFailureCount      = "x-failure-count"
+)
+
+// DeadLetterQueue публикует сообщения, которые не удалось обработать,
+// в отдельный топик, чтобы их можно было разобрать и переотправить вручную
+type DeadLetterQueue struct {
+	producer *Producer
+	topic    string
+}
+
+func NewDeadLetterQueue(producer *Producer, topic string) *DeadLetterQueue {
+	return &DeadLetterQueue{producer: producer, topic: topic}
+}
+
+func (d *DeadLetterQueue) Topic() string {
+	return d.topic
+}
+
+// Publish копирует ключ и тело исходного сообщения в dead-letter топик.
+// attempts - сколько раз сообщение пытались обработать; если сообщение
+// уже побывало в DLQ и было переотправлено, счётчик неудач продолжает расти
+func (d *DeadLetterQueue) Publish(msg *kafka.Message, cause error, attempts int) error {
+	failures := failureCount(msg.Headers) + attempts
+
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	for _, h := range msg.Headers {
+		if isDeadLetterHeader(h.Key) {
+			continue
+		}
+		headers = append(headers, h)
+	}
+
+	var originalTopic string
+	if msg.TopicPartition.Topic != nil {
+		originalTopic = *msg.TopicPartition.Topic
+	}
+
+	headers = append(headers,
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(originalTopic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(int(msg.TopicPartition.Partition)))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))},
+		kafka.Header{Key: HeaderError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderErrorClass, Value: []byte(retry.Classify(cause).String())},
+		kafka.Header{Key: HeaderFailureCount, Value: []byte(strconv.Itoa(failures))},
+	)
+
+	dlqMsg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &d.topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+
+	if err := d.producer.ProduceMessage(dlqMsg); err != nil {
+		return fmt.Errorf("error with publish to dead-letter topic %s: %w", d.topic, err)
+	}
+
+	return nil
+}
+
+func failureCount(headers []kafka.Header) int {
+	for _, h := range headers {
+		if h.Key != HeaderFailureCount {
+			continue
+		}
+		n, err := strconv.Atoi(string(h.Value))
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	return 0
+}
+
+func isDeadLetterHeader(key string) bool {
+	switch key {
+	case HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset,
+		HeaderError, HeaderErrorClass, HeaderFailureCount:
+		return true
+	}
+	return false
+}
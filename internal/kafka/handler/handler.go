@@ -3,51 +3,153 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel/attribute"
 
 	cache "github.com/sayhellolexa/order-service/internal/domain/cache"
 	order "github.com/sayhellolexa/order-service/internal/domain/order"
+	consumer "github.com/sayhellolexa/order-service/internal/kafka"
+	"github.com/sayhellolexa/order-service/internal/kafka/retry"
 	model "github.com/sayhellolexa/order-service/internal/model"
+	"github.com/sayhellolexa/order-service/internal/tracing"
 )
 
 type Handler struct {
 	orderRepository order.Repository
 	cacheRepository cache.Repository
+	logger          *slog.Logger
 }
 
-func NewHandler(orderRepository order.Repository, cacheRepository cache.Repository) *Handler {
-	return &Handler{orderRepository: orderRepository, cacheRepository: cacheRepository}
+// Option настраивает необязательные параметры обработчиков
+type Option func(*options)
+
+type options struct {
+	logger *slog.Logger
 }
 
-func (h *Handler) HandleMessage(ctx context.Context, message []byte, offset kafka.Offset) error {
-	log.Printf("Received message from Kafka with offset: %d", offset)
-	
-	err := h.orderRepository.SaveOrder(ctx, message)
-	if err != nil {
-		log.Printf("Error saving order to database: %v", err)
-		return fmt.Errorf("error with SaveOrder on kafka handler: %w", err)
+// WithLogger задаёт логгер обработчика. По умолчанию используется slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(&o)
 	}
+	return o
+}
+
+func NewHandler(orderRepository order.Repository, cacheRepository cache.Repository, opts ...Option) *Handler {
+	o := newOptions(opts)
+	return &Handler{orderRepository: orderRepository, cacheRepository: cacheRepository, logger: o.logger}
+}
+
+// HandleMessage сохраняет заказ в БД и кеш. Ошибки разбора, валидации
+// и конфликта содержимого помечаются как постоянные, остальные
+// считаются временными и могут быть повторены
+func (h *Handler) HandleMessage(ctx context.Context, message []byte, offset kafka.Offset) (err error) {
+	ctx, span := tracer.Start(ctx, "Handler.HandleMessage")
+	defer func() { tracing.End(span, err) }()
 
-	var order model.Order 
-	err = json.Unmarshal(message, &order)
+	h.logger.DebugContext(ctx, "Received order message")
+
+	ctx = withMessageSource(ctx)
+
+	err = h.orderRepository.SaveOrder(ctx, message)
 	if err != nil {
-		log.Printf("Error unmarshaling order from Kafka message: %v", err)
-		return fmt.Errorf("error with Unmarshal on kafka handler: %w", err)
+		h.logger.ErrorContext(ctx, "Error saving order to database", "error", err)
+		return classifySaveError(err)
 	}
 
-	log.Printf("Successfully unmarshaled order with ID: %s", order.OrderUID)
-	
-	err = h.cacheRepository.Set(ctx, &order, time.Hour * 72)
+	order, err := h.cacheOrder(ctx, message)
 	if err != nil {
-		log.Printf("Error setting order in cache: %v", err)
-		return fmt.Errorf("error with set cache on kafka handler: %w", err)
+		return err
 	}
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
 
-	log.Printf("Message from Kafka with offset save to db and cache: %d, order ID: %s", offset, order.OrderUID)
+	h.logger.InfoContext(ctx, "Order saved to database and cache", "order", order)
 
 	return nil
 }
+
+// HandleBatch сохраняет пакет заказов в БД одной транзакцией и кеширует
+// сохранённые. Возвращает ошибку для каждого сообщения пакета
+func (h *Handler) HandleBatch(ctx context.Context, messages [][]byte) (errs []error) {
+	ctx, span := tracer.Start(ctx, "Handler.HandleBatch")
+	defer func() { tracing.End(span, errors.Join(errs...)) }()
+
+	h.logger.DebugContext(ctx, "Received batch of order messages")
+
+	positions := consumer.BatchFromContext(ctx)
+	ctx = withBatchSources(ctx)
+
+	errs = h.orderRepository.SaveOrders(ctx, messages)
+
+	saved := 0
+	for i, message := range messages {
+		if errs[i] != nil {
+			attrs := []any{"index", i, "error", errs[i]}
+			if i < len(positions) {
+				attrs = append(attrs, "partition", positions[i].Partition, "offset", int64(positions[i].Offset))
+			}
+			h.logger.ErrorContext(ctx, "Error saving order to database", attrs...)
+			errs[i] = classifySaveError(errs[i])
+			continue
+		}
+
+		if _, err := h.cacheOrder(ctx, message); err != nil {
+			errs[i] = err
+			continue
+		}
+		saved++
+	}
+
+	h.logger.InfoContext(ctx, "Batch saved to database and cache", "saved", saved, "total", len(messages))
+	span.SetAttributes(attribute.Int("orders.saved", saved))
+
+	return errs
+}
+
+// classifySaveError помечает ошибки, которые не исправить повтором, как постоянные
+func classifySaveError(err error) error {
+	err = fmt.Errorf("error with SaveOrder on kafka handler: %w", err)
+	if errors.Is(err, order.ErrInvalidOrder) || errors.Is(err, order.ErrOrderConflict) {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// cacheOrder кладёт сохранённый в БД заказ в кеш. Заказ перечитывается
+// из БД, чтобы в кеш попал его текущий статус, а не только содержимое сообщения
+func (h *Handler) cacheOrder(ctx context.Context, message []byte) (*model.Order, error) {
+	var msg model.Order 
+	err := json.Unmarshal(message, &msg)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling order", "error", err)
+		return nil, retry.Permanent(fmt.Errorf("error with Unmarshal on kafka handler: %w", err))
+	}
+
+	order, err := h.orderRepository.GetOrderById(ctx, msg.OrderUID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Error reading saved order", "order_uid", msg.OrderUID, "error", err)
+		return nil, fmt.Errorf("error with GetOrderById on kafka handler: %w", err)
+	}
+	if order == nil {
+		return nil, fmt.Errorf("error with GetOrderById on kafka handler: order %s not found after save", msg.OrderUID)
+	}
+	
+	err = h.cacheRepository.Set(ctx, order)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Error setting order in cache", "order_uid", msg.OrderUID, "error", err)
+		return nil, fmt.Errorf("error with set cache on kafka handler: %w", err)
+	}
+
+	return order, nil
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	order "github.com/sayhellolexa/order-service/internal/domain/order"
+	consumer "github.com/sayhellolexa/order-service/internal/kafka"
+)
+
+// withMessageSource помечает изменения, сделанные в ctx, позицией
+// обрабатываемого сообщения Kafka
+func withMessageSource(ctx context.Context) context.Context {
+	tp, ok := consumer.MessageFromContext(ctx)
+	if !ok {
+		return order.WithSource(ctx, order.Source{Type: order.SourceKafka})
+	}
+	return order.WithSource(ctx, kafkaSource(tp))
+}
+
+// withBatchSources помечает изменения пакета позициями его сообщений
+func withBatchSources(ctx context.Context) context.Context {
+	positions := consumer.BatchFromContext(ctx)
+	sources := make([]order.Source, len(positions))
+	for i, tp := range positions {
+		sources[i] = kafkaSource(tp)
+	}
+	return order.WithBatchSources(order.WithSource(ctx, order.Source{Type: order.SourceKafka}), sources)
+}
+
+// kafkaSource описывает сообщение как topic[partition]@offset
+func kafkaSource(tp kafka.TopicPartition) order.Source {
+	var topic string
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return order.Source{Type: order.SourceKafka, ID: fmt.Sprintf("%s[%d]@%d", topic, tp.Partition, tp.Offset)}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	cache "github.com/sayhellolexa/order-service/internal/domain/cache"
+	order "github.com/sayhellolexa/order-service/internal/domain/order"
+	"github.com/sayhellolexa/order-service/internal/kafka/retry"
+	model "github.com/sayhellolexa/order-service/internal/model"
+	"github.com/sayhellolexa/order-service/internal/tracing"
+)
+
+// StatusHandler применяет события смены статуса заказа из отдельного топика
+type StatusHandler struct {
+	orderRepository order.Repository
+	cacheRepository cache.Repository
+	logger          *slog.Logger
+}
+
+func NewStatusHandler(orderRepository order.Repository, cacheRepository cache.Repository, opts ...Option) *StatusHandler {
+	o := newOptions(opts)
+	return &StatusHandler{orderRepository: orderRepository, cacheRepository: cacheRepository, logger: o.logger}
+}
+
+// HandleMessage переводит заказ в статус из события и обновляет кеш.
+// Битое событие и запрещённый переход - постоянные ошибки. Событие для ещё
+// не сохранённого заказа считается временной ошибкой: заказ может прийти позже
+func (h *StatusHandler) HandleMessage(ctx context.Context, message []byte, offset kafka.Offset) (err error) {
+	ctx, span := tracer.Start(ctx, "StatusHandler.HandleMessage")
+	defer func() { tracing.End(span, err) }()
+
+	h.logger.DebugContext(ctx, "Received status event")
+
+	ctx = withMessageSource(ctx)
+
+	var event model.StatusEvent
+	if err := json.Unmarshal(message, &event); err != nil {
+		return retry.Permanent(fmt.Errorf("error with Unmarshal on status handler: %w", err))
+	}
+
+	status, err := model.ParseOrderStatus(string(event.Status))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("error with status event: %w", err))
+	}
+
+	updated, err := h.orderRepository.UpdateStatus(ctx, event.OrderUID, status)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "Error updating order status", "order_uid", event.OrderUID, "error", err)
+		err = fmt.Errorf("error with UpdateStatus on status handler: %w", err)
+		if errors.Is(err, order.ErrInvalidTransition) {
+			return retry.Permanent(err)
+		}
+		return err
+	}
+
+	if err := h.cacheRepository.Set(ctx, updated); err != nil {
+		h.logger.ErrorContext(ctx, "Error setting order in cache", "order_uid", updated.OrderUID, "error", err)
+		return fmt.Errorf("error with set cache on status handler: %w", err)
+	}
+
+	h.logger.InfoContext(ctx, "Order status changed", "order_uid", updated.OrderUID, "status", updated.Status)
+
+	return nil
+}
@@ -0,0 +1,5 @@
+package handler
+
+import "go.opentelemetry.io/otel"
+
+var tracer = otel.Tracer("github.com/sayhellolexa/order-service/internal/kafka/handler")
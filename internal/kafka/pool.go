@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Сколько сообщений может ждать своей очереди у одного воркера
+const workerQueueSize = 64
+
+// workerPool раздаёт сообщения воркерам по ключу или номеру партиции
+// и сохраняет оффсеты только после того, как в партиции обработаны
+// все предыдущие сообщения
+type workerPool struct {
+	consumer *Consumer
+	queues   []chan *kafka.Message
+	tracker  *offsetTracker
+	inflight sync.WaitGroup
+	workers  sync.WaitGroup
+}
+
+func newWorkerPool(consumer *Consumer, size int) *workerPool {
+	queues := make([]chan *kafka.Message, size)
+	for i := range queues {
+		queues[i] = make(chan *kafka.Message, workerQueueSize)
+	}
+
+	return &workerPool{
+		consumer: consumer,
+		queues:   queues,
+		tracker:  newOffsetTracker(),
+	}
+}
+
+func (p *workerPool) start(ctx, handleCtx context.Context) {
+	for _, queue := range p.queues {
+		p.workers.Add(1)
+		go p.work(ctx, handleCtx, queue)
+	}
+}
+
+// dispatch ставит сообщение в очередь воркера. Если очередь заполнена,
+// чтение из Kafka приостанавливается, пока воркер не освободится
+func (p *workerPool) dispatch(kafkaMsg *kafka.Message) {
+	p.tracker.add(kafkaMsg.TopicPartition)
+	p.inflight.Add(1)
+	p.queues[p.route(kafkaMsg)] <- kafkaMsg
+}
+
+// route выбирает воркера: по ключу сообщения, а для сообщений без ключа - по партиции
+func (p *workerPool) route(kafkaMsg *kafka.Message) int {
+	h := fnv.New32a()
+	if len(kafkaMsg.Key) > 0 {
+		h.Write(kafkaMsg.Key)
+	} else {
+		h.Write([]byte(strconv.Itoa(int(kafkaMsg.TopicPartition.Partition))))
+	}
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+func (p *workerPool) work(ctx, handleCtx context.Context, queue <-chan *kafka.Message) {
+	defer p.workers.Done()
+
+	for kafkaMsg := range queue {
+		p.handle(ctx, handleCtx, kafkaMsg)
+	}
+}
+
+func (p *workerPool) handle(ctx, handleCtx context.Context, kafkaMsg *kafka.Message) {
+	defer p.inflight.Done()
+
+	// После остановки сообщения из очереди не обрабатываются: их оффсеты
+	// не сохраняются, и после рестарта они будут прочитаны заново
+	if ctx.Err() != nil {
+		return
+	}
+
+	if p.consumer.process(ctx, handleCtx, kafkaMsg) == outcomeUnfinished {
+		return
+	}
+
+	if tp, ok := p.tracker.complete(kafkaMsg.TopicPartition); ok {
+		p.consumer.store(tp)
+	}
+}
+
+// drain дожидается обработки всех розданных сообщений
+func (p *workerPool) drain() {
+	p.inflight.Wait()
+}
+
+// stop закрывает очереди и дожидается завершения воркеров
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.workers.Wait()
+}
+
+// offsetTracker отслеживает сообщения, которые ещё обрабатываются,
+// отдельно для каждой партиции
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+type partitionOffsets struct {
+	pending []kafka.Offset // оффсеты в порядке чтения
+	done    map[kafka.Offset]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+func keyOf(tp kafka.TopicPartition) partitionKey {
+	var topic string
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return partitionKey{topic: topic, partition: tp.Partition}
+}
+
+// add регистрирует прочитанное сообщение
+func (t *offsetTracker) add(tp kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := keyOf(tp)
+	offsets, ok := t.partitions[key]
+	if !ok {
+		offsets = &partitionOffsets{done: make(map[kafka.Offset]bool)}
+		t.partitions[key] = offsets
+	}
+	offsets.pending = append(offsets.pending, tp.Offset)
+}
+
+// complete отмечает сообщение обработанным. Если после этого в начале
+// партиции образовалась непрерывная цепочка обработанных сообщений,
+// возвращает последнее из них - его оффсет можно сохранять
+func (t *offsetTracker) complete(tp kafka.TopicPartition) (kafka.TopicPartition, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offsets, ok := t.partitions[keyOf(tp)]
+	if !ok {
+		return tp, false
+	}
+	offsets.done[tp.Offset] = true
+
+	var last kafka.Offset
+	advanced := false
+	for len(offsets.pending) > 0 && offsets.done[offsets.pending[0]] {
+		last = offsets.pending[0]
+		delete(offsets.done, last)
+		offsets.pending = offsets.pending[1:]
+		advanced = true
+	}
+
+	if !advanced {
+		return tp, false
+	}
+
+	tp.Offset = last
+	return tp, true
+}
+
+// forget удаляет состояние отозванных партиций
+func (t *offsetTracker) forget(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range partitions {
+		delete(t.partitions, keyOf(tp))
+	}
+}
@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetTracker_Complete(t *testing.T) {
+	topic := "orders"
+	at := func(partition int32, offset kafka.Offset) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: offset}
+	}
+
+	tracker := newOffsetTracker()
+	for _, offset := range []kafka.Offset{10, 11, 12} {
+		tracker.add(at(0, offset))
+	}
+	tracker.add(at(1, 5))
+
+	// Более позднее сообщение не двигает оффсет, пока не обработано предыдущее
+	_, ok := tracker.complete(at(0, 11))
+	assert.False(t, ok)
+
+	tp, ok := tracker.complete(at(0, 10))
+	assert.True(t, ok)
+	assert.Equal(t, kafka.Offset(11), tp.Offset)
+
+	// Партиции независимы друг от друга
+	tp, ok = tracker.complete(at(1, 5))
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), tp.Partition)
+	assert.Equal(t, kafka.Offset(5), tp.Offset)
+
+	tp, ok = tracker.complete(at(0, 12))
+	assert.True(t, ok)
+	assert.Equal(t, kafka.Offset(12), tp.Offset)
+}
+
+func TestOffsetTracker_Forget(t *testing.T) {
+	topic := "orders"
+	tp := kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: 1}
+
+	tracker := newOffsetTracker()
+	tracker.add(tp)
+	tracker.forget([]kafka.TopicPartition{tp})
+
+	_, ok := tracker.complete(tp)
+	assert.False(t, ok)
+}
+
+func TestWorkerPool_RouteKeepsKeyOnOneWorker(t *testing.T) {
+	pool := newWorkerPool(&Consumer{}, 8)
+	topic := "orders"
+
+	first := pool.route(&kafka.Message{Key: []byte("order-1"), TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0}})
+	second := pool.route(&kafka.Message{Key: []byte("order-1"), TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3}})
+	assert.Equal(t, first, second)
+
+	noKey := pool.route(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 2}})
+	assert.Equal(t, noKey, pool.route(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 2}}))
+}
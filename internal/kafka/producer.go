@@ -1,11 +1,14 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	"github.com/sayhellolexa/order-service/internal/tracing"
 )
 
 const(
@@ -31,7 +34,8 @@ func NewProducer (address []string) (*Producer, error) {
 	return &Producer{producer: p}, nil
 }
 
-func (p *Producer) Produce(topic string, data []byte) error {
+// Produce отправляет сообщение, продолжая трассировку из ctx
+func (p *Producer) Produce(ctx context.Context, topic string, data []byte) error {
 	kafkaMsg := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &topic,
@@ -40,6 +44,36 @@ func (p *Producer) Produce(topic string, data []byte) error {
 		Value: data, // <-- сразу передаём байты
 	}
 
+	return p.produceTraced(ctx, kafkaMsg)
+}
+
+// ProduceWithKey отправляет сообщение с ключом: сообщения с одним ключом
+// попадают в одну партицию и обрабатываются по порядку
+func (p *Producer) ProduceWithKey(ctx context.Context, topic string, key []byte, data []byte) error {
+	kafkaMsg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:   key,
+		Value: data,
+	}
+
+	return p.produceTraced(ctx, kafkaMsg)
+}
+
+// produceTraced отправляет сообщение в спане отправки, контекст которого
+// передаётся консьюмеру в заголовках
+func (p *Producer) produceTraced(ctx context.Context, kafkaMsg *kafka.Message) error {
+	span := startSendSpan(ctx, kafkaMsg)
+	err := p.ProduceMessage(kafkaMsg)
+	tracing.End(span, err)
+	return err
+}
+
+// ProduceMessage отправляет готовое сообщение (с ключом и заголовками)
+// и ждёт подтверждения доставки
+func (p *Producer) ProduceMessage(kafkaMsg *kafka.Message) error {
 	kafkaChan := make(chan kafka.Event, 1)
 
 	if err := p.producer.Produce(kafkaMsg, kafkaChan); err != nil {
@@ -49,6 +83,9 @@ func (p *Producer) Produce(topic string, data []byte) error {
 	e := <-kafkaChan
 	switch ev := e.(type) {
 	case *kafka.Message:
+		if ev.TopicPartition.Error != nil {
+			return fmt.Errorf("error with delivery: %w", ev.TopicPartition.Error)
+		}
 		return nil
 	case kafka.Error:
 		return ev
@@ -62,4 +99,4 @@ func (p *Producer) Produce(topic string, data []byte) error {
 func (p *Producer) Close() {
 	p.producer.Flush(flushTimeout)
 	p.producer.Close()
-}
\ No newline at end of file
+}
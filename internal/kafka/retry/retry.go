@@ -0,0 +1,118 @@
+package retry
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Class определяет, имеет ли смысл повторять обработку сообщения
+type Class int
+
+const (
+	// ClassTransient - временный сбой (потеря соединения с Postgres, таймаут Redis),
+	// повторная попытка может пройти успешно
+	ClassTransient Class = iota
+	// ClassPermanent - сообщение невозможно обработать (битый JSON, невалидный заказ),
+	// повторять обработку бессмысленно
+	ClassPermanent
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassTransient:
+		return "transient"
+	case ClassPermanent:
+		return "permanent"
+	default:
+		return "unknown"
+	}
+}
+
+type classifiedError struct {
+	class Class
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// Permanent помечает ошибку как постоянную
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: ClassPermanent, err: err}
+}
+
+// Transient помечает ошибку как временную
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: ClassTransient, err: err}
+}
+
+// Classify возвращает класс ошибки. Неразмеченные ошибки считаются временными
+func Classify(err error) Class {
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+	return ClassTransient
+}
+
+// Policy описывает, сколько раз и с какими паузами повторять обработку
+type Policy struct {
+	MaxAttempts    int           // общее число попыток, включая первую
+	InitialBackoff time.Duration // пауза после первой неудачи
+	MaxBackoff     time.Duration // верхняя граница паузы
+	Multiplier     float64       // во сколько раз растёт пауза с каждой попыткой
+	Jitter         float64       // случайное отклонение паузы, доля от 0 до 1
+}
+
+// DefaultPolicies возвращает политики по умолчанию: временные ошибки
+// повторяются с экспоненциальной паузой, постоянные - сразу уходят дальше
+func DefaultPolicies() map[Class]Policy {
+	return map[Class]Policy{
+		ClassTransient: {
+			MaxAttempts:    5,
+			InitialBackoff: 200 * time.Millisecond,
+			MaxBackoff:     10 * time.Second,
+			Multiplier:     2,
+			Jitter:         0.2,
+		},
+		ClassPermanent: {
+			MaxAttempts: 1,
+		},
+	}
+}
+
+// Backoff возвращает паузу перед следующей попыткой после attempt неудачных
+func (p Policy) Backoff(attempt int) time.Duration {
+	if attempt < 1 || p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
@@ -0,0 +1,55 @@
+package retry_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sayhellolexa/order-service/internal/kafka/retry"
+)
+
+func TestClassify(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name string
+		err  error
+		want retry.Class
+	}{
+		{"plain error", base, retry.ClassTransient},
+		{"permanent", retry.Permanent(base), retry.ClassPermanent},
+		{"wrapped permanent", fmt.Errorf("handler: %w", retry.Permanent(base)), retry.ClassPermanent},
+		{"transient", retry.Transient(base), retry.ClassTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retry.Classify(tt.err))
+			assert.ErrorIs(t, tt.err, base)
+		})
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	policy := retry.Policy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 800*time.Millisecond, policy.Backoff(4))
+	assert.Equal(t, time.Second, policy.Backoff(10))
+
+	policy.Jitter = 0.5
+	for range 100 {
+		delay := policy.Backoff(2)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 300*time.Millisecond)
+	}
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/sayhellolexa/order-service/internal/kafka")
+
+// headerCarrier передаёт контекст трассировки через заголовки сообщения
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// messageAttributes описывает сообщение в атрибутах спана
+func messageAttributes(tp kafka.TopicPartition) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemKafka,
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(int(tp.Partition))),
+	}
+	if tp.Topic != nil {
+		attrs = append(attrs, semconv.MessagingDestinationName(*tp.Topic))
+	}
+	if tp.Offset >= 0 {
+		attrs = append(attrs, semconv.MessagingKafkaOffset(int(tp.Offset)))
+	}
+	return attrs
+}
+
+// startSendSpan начинает спан отправки и записывает его контекст в заголовки сообщения
+func startSendSpan(ctx context.Context, kafkaMsg *kafka.Message) trace.Span {
+	ctx, span := tracer.Start(ctx, "send "+*kafkaMsg.TopicPartition.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconv.MessagingOperationTypeSend),
+		trace.WithAttributes(messageAttributes(kafkaMsg.TopicPartition)...),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{&kafkaMsg.Headers})
+	return span
+}
+
+// startProcessSpan продолжает трассировку из заголовков сообщения спаном его обработки
+func startProcessSpan(ctx context.Context, kafkaMsg *kafka.Message) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{&kafkaMsg.Headers})
+	return tracer.Start(ctx, "process "+*kafkaMsg.TopicPartition.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(semconv.MessagingOperationTypeProcess),
+		trace.WithAttributes(messageAttributes(kafkaMsg.TopicPartition)...),
+	)
+}
+
+// startBatchSpan начинает спан обработки пакета. У сообщений пакета разные
+// трассировки, поэтому они не родители спана, а связи с ним
+func startBatchSpan(ctx context.Context, batch []*kafka.Message) (context.Context, trace.Span) {
+	links := make([]trace.Link, 0, len(batch))
+	for _, kafkaMsg := range batch {
+		msgCtx := otel.GetTextMapPropagator().Extract(ctx, headerCarrier{&kafkaMsg.Headers})
+		if sc := trace.SpanContextFromContext(msgCtx); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc, Attributes: messageAttributes(kafkaMsg.TopicPartition)})
+		}
+	}
+
+	return tracer.Start(ctx, "process "+*batch[0].TopicPartition.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingBatchMessageCount(len(batch)),
+		),
+	)
+}
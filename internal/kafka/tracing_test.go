@@ -0,0 +1,33 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHeaderCarrier(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "send")
+	defer span.End()
+
+	propagator := propagation.TraceContext{}
+	headers := []kafka.Header{{Key: "traceparent", Value: []byte("stale")}, {Key: "other", Value: []byte("1")}}
+
+	propagator.Inject(ctx, headerCarrier{&headers})
+
+	// Устаревший заголовок перезаписывается, а не дублируется
+	require.Len(t, headers, 2)
+	assert.Equal(t, "other", headers[1].Key)
+
+	extracted := trace.SpanContextFromContext(propagator.Extract(context.Background(), headerCarrier{&headers}))
+	require.True(t, extracted.IsValid())
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	return entry
+}
+
+func TestLogger_ContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Settings{Format: FormatJSON, Level: slog.LevelInfo})
+
+	ctx := With(context.Background(), "request_id", "req-1")
+	ctx = With(ctx, "partition", 2, "offset", int64(42))
+	log.InfoContext(ctx, "Order saved")
+
+	entry := decode(t, &buf)
+	assert.Equal(t, "Order saved", entry["msg"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.EqualValues(t, 2, entry["partition"])
+	assert.EqualValues(t, 42, entry["offset"])
+
+	// Контекст, из которого создан дочерний, не получает его атрибутов
+	buf.Reset()
+	log.InfoContext(With(context.Background(), "request_id", "req-2"), "Other request")
+	entry = decode(t, &buf)
+	assert.Equal(t, "req-2", entry["request_id"])
+	assert.NotContains(t, entry, "offset")
+}
+
+func TestLogger_Level(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Settings{Format: FormatText, Level: slog.LevelWarn})
+
+	log.Info("hidden")
+	assert.Empty(t, buf.String())
+
+	log.Warn("shown")
+	assert.Contains(t, buf.String(), "msg=shown")
+}
+
+func TestLogger_Redact(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Settings{Format: FormatJSON, Level: slog.LevelInfo})
+
+	delivery := model.Delivery{
+		Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+		Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+	}
+	log.Info("Delivery of john@example.com, call +79991234567",
+		"delivery", delivery, "name", "Sneakers", "email", "x@y.io")
+
+	entry := decode(t, &buf)
+	assert.Equal(t, "Delivery of j**************m, call +**********7", entry["msg"])
+	assert.Equal(t, "Sneakers", entry["name"])
+	assert.Equal(t, "x****o", entry["email"])
+
+	group, ok := entry["delivery"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "T*********v", group["name"])
+	assert.Equal(t, "+*********0", group["phone"])
+	assert.Equal(t, "P*************5", group["address"])
+	assert.Equal(t, "K****t", group["region"])
+	assert.Equal(t, "t************m", group["email"])
+}
+
+func TestLogger_OrderSummary(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Settings{Format: FormatJSON, Level: slog.LevelInfo})
+
+	order := model.Order{
+		OrderUID: "b563feb7b2b84b6test", TrackNumber: "WBILMTESTTRACK", Status: model.StatusCreated,
+		Delivery: model.Delivery{Name: "Test Testov", Email: "test@gmail.com"},
+		Items:    []model.Item{{Name: "Mascaras"}},
+	}
+	log.Info("Order saved", "order", order)
+
+	assert.NotContains(t, buf.String(), "Testov")
+	assert.NotContains(t, buf.String(), "gmail")
+
+	group, ok := decode(t, &buf)["order"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "b563feb7b2b84b6test", group["order_uid"])
+	assert.EqualValues(t, 1, group["items"])
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "", Mask(""))
+	assert.Equal(t, "**", Mask("ab"))
+	assert.Equal(t, "И**н", Mask("Иван"))
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Ключи, значения которых всегда маскируются
+var sensitiveKeys = map[string]bool{
+	"phone":   true,
+	"email":   true,
+	"address": true,
+}
+
+// Ключи, которые маскируются только внутри группы delivery: имя
+// товара и имя получателя называются одинаково
+var deliveryKeys = map[string]bool{
+	"name":   true,
+	"city":   true,
+	"zip":    true,
+	"region": true,
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[^\s@"']+@[^\s@"']+\.[a-zA-Z]{2,}`)
+	// Без плюса телефон не отличить от оффсета или суммы
+	phonePattern = regexp.MustCompile(`\+[1-9][0-9]{6,14}\b`)
+)
+
+// redact маскирует персональные данные получателя: по ключу атрибута,
+// а в остальных строках, включая сообщение, - встречающиеся email и телефоны
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindString {
+		return a
+	}
+
+	key := strings.ToLower(a.Key)
+	if sensitiveKeys[key] || (deliveryKeys[key] && slices.Contains(groups, "delivery")) {
+		return slog.String(a.Key, Mask(a.Value.String()))
+	}
+
+	value := a.Value.String()
+	masked := emailPattern.ReplaceAllStringFunc(value, Mask)
+	masked = phonePattern.ReplaceAllStringFunc(masked, Mask)
+	if masked != value {
+		return slog.String(a.Key, masked)
+	}
+	return a
+}
+
+// Mask оставляет первый и последний символ значения, остальные заменяет звёздочками
+func Mask(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
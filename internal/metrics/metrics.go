@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Префикс всех метрик сервиса
+const namespace = "orders"
+
+// HTTP API
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Кеш
+var (
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by tier (local, redis) and result (hit, miss, error).",
+	}, []string{"tier", "result"})
+
+	CacheErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "errors_total",
+		Help:      "Failed Redis cache operations by operation.",
+	}, []string{"operation"})
+
+	CacheSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "skipped_total",
+		Help:      "Orders not cached because they exceed the cache policy size limits.",
+	})
+
+	PreloadOrders = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "preload_orders",
+		Help:      "Progress of the current cache preload: total, scanned and loaded orders.",
+	}, []string{"state"})
+)
+
+// База данных
+var DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "db",
+	Name:      "query_duration_seconds",
+	Help:      "Order repository call latency by query.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"query"})
+
+// Kafka
+var (
+	KafkaMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Messages read from Kafka by topic.",
+	}, []string{"topic"})
+
+	KafkaHandlerFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "handler_failures_total",
+		Help:      "Failed message handling attempts by topic and error class (transient, permanent).",
+	}, []string{"topic", "class"})
+
+	KafkaDeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "dead_letters_total",
+		Help:      "Messages moved to the dead-letter topic by source topic.",
+	}, []string{"topic"})
+
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages between the last read offset and the high watermark by topic and partition.",
+	}, []string{"topic", "partition"})
+)
+
+// ObserveQuery записывает длительность запроса к БД, начатого в start.
+// Удобно вызывать через defer: defer metrics.ObserveQuery("get_order", time.Now())
+func ObserveQuery(query string, start time.Time) {
+	DBQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
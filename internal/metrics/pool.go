@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBPoolStats - снимок состояния пула подключений к БД
+type DBPoolStats struct {
+	MaxConns      int32
+	TotalConns    int32
+	IdleConns     int32
+	AcquiredConns int32
+	// Сколько раз подключение выдавалось из пула, сколько раз пришлось
+	// ждать свободного и сколько ожиданий отменено
+	AcquireCount         int64
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+	// Суммарное время получения подключений
+	AcquireDuration time.Duration
+}
+
+func dbPoolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+}
+
+var (
+	dbPoolMaxConns      = dbPoolDesc("max_connections", "Maximum size of the database connection pool.")
+	dbPoolTotalConns    = dbPoolDesc("connections", "Open connections in the database pool.")
+	dbPoolIdleConns     = dbPoolDesc("idle_connections", "Idle connections in the database pool.")
+	dbPoolAcquiredConns = dbPoolDesc("acquired_connections", "Connections currently in use.")
+	dbPoolAcquires      = dbPoolDesc("acquires_total", "Connections acquired from the pool.")
+	dbPoolEmptyAcquires = dbPoolDesc("empty_acquires_total", "Acquires that waited because the pool had no idle connection.")
+	dbPoolCanceled      = dbPoolDesc("canceled_acquires_total", "Acquires canceled by the caller while waiting.")
+	dbPoolAcquireTime   = dbPoolDesc("acquire_duration_seconds_total", "Total time spent acquiring connections.")
+)
+
+// dbPoolCollector снимает статистику пула при каждом сборе метрик
+type dbPoolCollector struct {
+	stats func() DBPoolStats
+}
+
+func (c dbPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		dbPoolMaxConns, dbPoolTotalConns, dbPoolIdleConns, dbPoolAcquiredConns,
+		dbPoolAcquires, dbPoolEmptyAcquires, dbPoolCanceled, dbPoolAcquireTime,
+	} {
+		ch <- desc
+	}
+}
+
+func (c dbPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(dbPoolMaxConns, prometheus.GaugeValue, float64(s.MaxConns))
+	ch <- prometheus.MustNewConstMetric(dbPoolTotalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(dbPoolIdleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(dbPoolAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns))
+	ch <- prometheus.MustNewConstMetric(dbPoolAcquires, prometheus.CounterValue, float64(s.AcquireCount))
+	ch <- prometheus.MustNewConstMetric(dbPoolEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount))
+	ch <- prometheus.MustNewConstMetric(dbPoolCanceled, prometheus.CounterValue, float64(s.CanceledAcquireCount))
+	ch <- prometheus.MustNewConstMetric(dbPoolAcquireTime, prometheus.CounterValue, s.AcquireDuration.Seconds())
+}
+
+// RegisterDBPool экспортирует статистику пула подключений к БД, которую
+// возвращает stats. Вызывается один раз на процесс
+func RegisterDBPool(stats func() DBPoolStats) {
+	prometheus.MustRegister(dbPoolCollector{stats: stats})
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler отдаёт метрики в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ServeOption настраивает служебный HTTP-сервер
+type ServeOption func(*http.ServeMux)
+
+// WithHandler добавляет на служебный сервер обработчик pattern,
+// например эндпоинты проверки здоровья
+func WithHandler(pattern string, handler http.Handler) ServeOption {
+	return func(mux *http.ServeMux) {
+		mux.Handle(pattern, handler)
+	}
+}
+
+// Serve запускает в фоне служебный HTTP-сервер с /metrics на addr.
+// Остановить его можно через Shutdown возвращённого сервера
+func Serve(addr string, opts ...ServeOption) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	for _, opt := range opts {
+		opt(mux)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		slog.Info("Serving metrics", "addr", addr, "path", "/metrics")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server failed", "error", err)
+		}
+	}()
+
+	return srv
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "log/slog"
+
+// LogValue выводит в лог только сводку заказа: данные получателя и оплаты
+// в журнал не попадают
+func (o Order) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("order_uid", o.OrderUID),
+		slog.String("track_number", o.TrackNumber),
+		slog.String("status", string(o.Status)),
+		slog.Int("items", len(o.Items)),
+	)
+}
+
+// LogValue выводит данные получателя группой delivery, поля которой
+// маскирует логгер
+func (d Delivery) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("name", d.Name),
+		slog.String("phone", d.Phone),
+		slog.String("zip", d.Zip),
+		slog.String("city", d.City),
+		slog.String("address", d.Address),
+		slog.String("region", d.Region),
+		slog.String("email", d.Email),
+	)
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money - денежная сумма в минимальных единицах валюты (копейках, центах).
+// Суммы складываются и сравниваются точно, без ошибок округления float64.
+// В JSON и в PostgreSQL (NUMERIC) сумма записывается десятичным числом
+type Money int64
+
+// Unit - одна единица валюты: 5 * Unit - пять рублей
+const Unit Money = 100
+
+// ParseMoney разбирает десятичную запись суммы, например "18.17" или "1817".
+// Больше двух знаков после запятой не допускается: такую сумму нельзя
+// сохранить без округления
+func ParseMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	r.Mul(r, big.NewRat(int64(Unit), 1))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("amount %q has more than two decimal places", s)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	return Money(r.Num().Int64()), nil
+}
+
+// String возвращает сумму без лишних нулей дробной части: 1817, 18.1, 18.17.
+// Так же float64 записывал суммы в JSON, поэтому хеши заказов не меняются
+func (m Money) String() string {
+	sign := ""
+	value := uint64(m)
+	if m < 0 {
+		sign = "-"
+		value = uint64(-m)
+	}
+
+	units, cents := value/uint64(Unit), value%uint64(Unit)
+	if cents == 0 {
+		return sign + strconv.FormatUint(units, 10)
+	}
+	return sign + strings.TrimRight(fmt.Sprintf("%d.%02d", units, cents), "0")
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	money, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// Scan читает сумму из NUMERIC. Драйвер отдаёт NUMERIC строкой, поэтому
+// дробная часть не проходит через float64
+func (m *Money) Scan(src any) error {
+	var err error
+	switch v := src.(type) {
+	case string:
+		*m, err = ParseMoney(v)
+	case []byte:
+		*m, err = ParseMoney(string(v))
+	case int64:
+		*m = Money(v) * Unit
+	case float64:
+		*m = Money(math.Round(v * float64(Unit)))
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return err
+}
+
+// Value записывает сумму десятичной строкой, которую PostgreSQL приводит
+// к NUMERIC без потерь
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
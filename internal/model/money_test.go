@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"1817", 1817 * Unit},
+		{"18.17", 1817},
+		{"18.1", 1810},
+		{"0.07", 7},
+		{"-5.5", -550},
+		{"1.5e3", 1500 * Unit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMoney(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, in := range []string{"", "abc", "1.005", "1e30"} {
+		_, err := ParseMoney(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "1817", (1817 * Unit).String())
+	assert.Equal(t, "18.17", Money(1817).String())
+	assert.Equal(t, "18.1", Money(1810).String())
+	assert.Equal(t, "0.07", Money(7).String())
+	assert.Equal(t, "-5.5", Money(-550).String())
+	assert.Equal(t, "0", Money(0).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var payment Payment
+	require.NoError(t, json.Unmarshal([]byte(`{"amount": 18.17, "delivery_cost": 1500, "goods_total": 0.1}`), &payment))
+	assert.Equal(t, Money(1817), payment.Amount)
+	assert.Equal(t, 1500*Unit, payment.DeliveryCost)
+	assert.Equal(t, Money(10), payment.GoodsTotal)
+
+	data, err := json.Marshal(Item{Price: 45350, TotalPrice: 31700})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"price":453.5`)
+	assert.Contains(t, string(data), `"total_price":317`)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.001}`), &payment))
+}
+
+func TestMoney_Scan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Money
+	}{
+		{"18.17", 1817},
+		{[]byte("1500.00"), 1500 * Unit},
+		{int64(317), 317 * Unit},
+		{float64(18.17), 1817},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		require.NoError(t, m.Scan(tt.src))
+		assert.Equal(t, tt.want, m)
+	}
+
+	var m Money
+	assert.Error(t, m.Scan(nil))
+
+	value, err := Money(1817).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "18.17", value)
+}
@@ -1,14 +1,14 @@
 package domain
 
 type Payment struct {
-	Transaction  string  `json:"transaction"`
-	RequestID    string  `json:"request_id"`
-	Currency     string  `json:"currency"`
-	Provider     string  `json:"provider"`
-	Amount       float64 `json:"amount"`
-	PaymentDt    int64   `json:"payment_dt"`
-	Bank         string  `json:"bank"`
-	DeliveryCost float64 `json:"delivery_cost"`
-	GoodsTotal   float64 `json:"goods_total"`
-	CustomFee    float64 `json:"custom_fee"`
+	Transaction  string `json:"transaction"`
+	RequestID    string `json:"request_id"`
+	Currency     string `json:"currency"`
+	Provider     string `json:"provider"`
+	Amount       Money  `json:"amount"`
+	PaymentDt    int64  `json:"payment_dt"`
+	Bank         string `json:"bank"`
+	DeliveryCost Money  `json:"delivery_cost"`
+	GoodsTotal   Money  `json:"goods_total"`
+	CustomFee    Money  `json:"custom_fee"`
 }
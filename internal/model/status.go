@@ -0,0 +1,53 @@
+package domain
+
+import "fmt"
+
+// OrderStatus - этап жизненного цикла заказа
+type OrderStatus string
+
+const (
+	StatusCreated    OrderStatus = "created"
+	StatusPaid       OrderStatus = "paid"
+	StatusAssembling OrderStatus = "assembling"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCancelled  OrderStatus = "cancelled"
+	StatusReturned   OrderStatus = "returned"
+)
+
+// statusTransitions - допустимые переходы между статусами.
+// Отменённый и возвращённый заказ больше не меняют статус
+var statusTransitions = map[OrderStatus][]OrderStatus{
+	StatusCreated:    {StatusPaid, StatusCancelled},
+	StatusPaid:       {StatusAssembling, StatusCancelled},
+	StatusAssembling: {StatusShipped, StatusCancelled},
+	StatusShipped:    {StatusDelivered, StatusReturned},
+	StatusDelivered:  {StatusReturned},
+	StatusCancelled:  {},
+	StatusReturned:   {},
+}
+
+// ParseOrderStatus проверяет, что статус известен
+func ParseOrderStatus(s string) (OrderStatus, error) {
+	status := OrderStatus(s)
+	if _, ok := statusTransitions[status]; !ok {
+		return "", fmt.Errorf("unknown order status %q", s)
+	}
+	return status, nil
+}
+
+// CanTransitionTo сообщает, можно ли перевести заказ из статуса s в next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusEvent - сообщение об изменении статуса заказа из Kafka
+type StatusEvent struct {
+	OrderUID string      `json:"order_uid"`
+	Status   OrderStatus `json:"status"`
+}
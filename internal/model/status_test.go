@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{StatusCreated, StatusPaid, true},
+		{StatusCreated, StatusShipped, false},
+		{StatusPaid, StatusAssembling, true},
+		{StatusAssembling, StatusShipped, true},
+		{StatusShipped, StatusDelivered, true},
+		{StatusShipped, StatusCancelled, false},
+		{StatusDelivered, StatusReturned, true},
+		{StatusCancelled, StatusPaid, false},
+		{StatusReturned, StatusDelivered, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.from.CanTransitionTo(tt.to))
+		})
+	}
+}
+
+func TestParseOrderStatus(t *testing.T) {
+	status, err := ParseOrderStatus("paid")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusPaid, status)
+
+	_, err = ParseOrderStatus("lost")
+	assert.Error(t, err)
+}
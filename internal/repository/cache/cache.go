@@ -6,146 +6,289 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 
+	domain "github.com/sayhellolexa/order-service/internal/domain/cache"
+	"github.com/sayhellolexa/order-service/internal/metrics"
 	model "github.com/sayhellolexa/order-service/internal/model"
 )
 
+// Заказ хранится в ключе order:<order_uid>, а отметка о том, что заказа нет
+// в БД, - в отдельном ключе notfound:<order_uid>. Так ключи order:* - это
+// только заказы, и их можно считать и сверять по шаблону
+const (
+	orderKeyPrefix    = "order:"
+	notFoundKeyPrefix = "notfound:"
+)
+
+// notFoundMarker - значение ключа отметки. Раньше отметка хранилась в ключе
+// заказа, поэтому в order:* она ещё может встретиться, пока не истечёт
+const notFoundMarker = "not-found"
+
+func orderKey(orderUID string) string {
+	return orderKeyPrefix + orderUID
+}
+
+func notFoundKey(orderUID string) string {
+	return notFoundKeyPrefix + orderUID
+}
+
+// countScanSize - сколько ключей просит у Redis один SCAN при подсчёте заказов
+const countScanSize = 1000
+
+// DefaultInvalidationChannel - канал Redis, в который публикуются
+// order_uid изменённых заказов
+const DefaultInvalidationChannel = "orders:invalidate"
+
 type RedisCache struct {
 	client *redis.Client
 	db *sql.DB
+	// Канал инвалидации; пустой - изменения не публикуются
+	channel string
+	// instanceID отличает свои сообщения об инвалидации от чужих
+	instanceID string
+	logger *slog.Logger
+	// policy задаёт время жизни заказов и ограничения их размера
+	policy domain.Policy
+	hits atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Option настраивает необязательные параметры RedisCache
+type Option func(*RedisCache)
+
+// WithInvalidation включает публикацию order_uid каждого записанного
+// заказа в channel, чтобы реплики сбросили его из локального кеша
+func WithInvalidation(channel string) Option {
+	return func(c *RedisCache) {
+		c.channel = channel
+	}
 }
 
-func NewRedisCacheRepository(client *redis.Client, db *sql.DB) *RedisCache {
-	return &RedisCache{client: client, db: db}
+// WithLogger задаёт логгер кеша
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *RedisCache) {
+		c.logger = logger
+	}
+}
+
+// WithPolicy задаёт политику кеширования заказов. Она действует на все
+// записи: Set, прогрев из БД и продление при чтении
+func WithPolicy(policy domain.Policy) Option {
+	return func(c *RedisCache) {
+		c.policy = policy
+	}
+}
+
+func NewRedisCacheRepository(client *redis.Client, db *sql.DB, opts ...Option) *RedisCache {
+	c := &RedisCache{client: client, db: db, instanceID: uuid.NewString(), logger: slog.Default(), policy: domain.DefaultPolicy()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Получить кеш
-func (c *RedisCache) Get(ctx context.Context, orderUID string) (*model.Order, error) {
-	key := fmt.Sprintf("order:%s", orderUID)
+func (c *RedisCache) Get(ctx context.Context, orderUID string) (_ *model.Order, err error) {
+	ctx, end := startCommand(ctx, "get", orderUID)
+	defer func() {
+		// Отметка об отсутствии заказа - ответ кеша, а не сбой
+		if errors.Is(err, domain.ErrNotFound) {
+			end(nil)
+			return
+		}
+		end(err)
+	}()
+
+	key := orderKey(orderUID)
 	
-	log.Printf("Attempting to get order from cache with ID: %s", orderUID)
+	c.logger.DebugContext(ctx, "Attempting to get order from cache", "order_uid", orderUID)
 	
-	val, err := c.client.Get(ctx, key).Result()
-	if err == nil {
-		log.Printf("Cache HIT for order ID: %s, data length: %d bytes", orderUID, len(val))
+	// Заказ и отметка читаются за одно обращение. Заказ важнее отметки:
+	// она могла остаться с тех пор, когда заказа ещё не было в БД
+	vals, err := c.client.MGet(ctx, key, notFoundKey(orderUID)).Result()
+	if err != nil {
+		metrics.CacheRequests.WithLabelValues("redis", "error").Inc()
+		metrics.CacheErrors.WithLabelValues("get").Inc()
+		c.logger.ErrorContext(ctx, "Redis error on GET", "order_uid", orderUID, "error", err)
+		return nil, fmt.Errorf("redis error on GET: %w", err)
+	}
+
+	val, found := vals[0].(string)
+	if _, missing := vals[1].(string); !found && missing {
+		val, found = notFoundMarker, true
+	}
+
+	if found {
+		c.hits.Add(1)
+		metrics.CacheRequests.WithLabelValues("redis", "hit").Inc()
+
+		if val == notFoundMarker {
+			c.logger.DebugContext(ctx, "Cache HIT for missing order", "order_uid", orderUID)
+			return nil, domain.ErrNotFound
+		}
+
+		c.logger.DebugContext(ctx, "Cache HIT", "order_uid", orderUID, "bytes", len(val))
 		
 		var order model.Order
 		if err := json.Unmarshal([]byte(val), &order); err != nil {
-			log.Printf("Failed to unmarshal order %s from cache: %v", orderUID, err)
+			c.logger.ErrorContext(ctx, "Failed to unmarshal order from cache", "order_uid", orderUID, "error", err)
 			return nil, fmt.Errorf("failed to unmarshal order from cache: %w", err)
 		}
+
+		if c.policy.Sliding {
+			c.touch(ctx, key, &order)
+		}
 		
-		log.Printf("Successfully unmarshaled order %s from cache", orderUID)
 		return &order, nil
 	}
 
-	if err != redis.Nil {
-		log.Printf("Redis error on GET for order %s: %v", orderUID, err)
-		return nil, fmt.Errorf("redis error on GET: %w", err)
-	} else {
-		log.Printf("Cache MISS for order ID: %s", orderUID)
-		return nil, nil
-	}
+	c.misses.Add(1)
+	metrics.CacheRequests.WithLabelValues("redis", "miss").Inc()
+	c.logger.DebugContext(ctx, "Cache MISS", "order_uid", orderUID)
+	return nil, nil
 }
 
-// Установить кеш
-func (c *RedisCache) Set(ctx context.Context, order *model.Order, ttl time.Duration) error {
-	key := fmt.Sprintf("order:%s", order.OrderUID)
-	
-	log.Printf("Attempting to cache order with ID: %s", order.OrderUID)
-	
+// Установить кеш на время, которое задаёт политика. Запись удаляет и отметку
+// об отсутствии заказа, так что только что сохранённый заказ сразу становится
+// виден. Заказ, который политика не кеширует, удаляется из кеша
+func (c *RedisCache) Set(ctx context.Context, order *model.Order) (err error) {
+	ctx, end := startCommand(ctx, "set", order.OrderUID)
+	defer func() { end(err) }()
+
+	_, err = c.store(ctx, order)
+	return err
+}
+
+// store записывает заказ и возвращает время его жизни в кеше. Ноль
+// означает, что политика заказ не кеширует
+func (c *RedisCache) store(ctx context.Context, order *model.Order) (time.Duration, error) {
+	key := orderKey(order.OrderUID)
+
 	data, err := json.Marshal(order)
 	if err != nil {
-		log.Printf("Failed to marshal order %s: %v", order.OrderUID, err)
-		return fmt.Errorf("failed to marshal order for cache: %w", err)
+		c.logger.ErrorContext(ctx, "Failed to marshal order", "order_uid", order.OrderUID, "error", err)
+		return 0, fmt.Errorf("failed to marshal order for cache: %w", err)
 	}
-	
-	log.Printf("Successfully marshaled order %s, data length: %d bytes", order.OrderUID, len(data))
-	
-	err = c.client.Set(ctx, key, data, ttl).Err()
+
+	if !c.policy.Cacheable(order, len(data)) {
+		return 0, c.skip(ctx, order, len(data))
+	}
+
+	ttl := c.policy.Expiration(order, time.Now())
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, ttl)
+		pipe.Del(ctx, notFoundKey(order.OrderUID))
+		return nil
+	})
 	if err != nil {
-		log.Printf("Failed to set order %s in cache: %v", order.OrderUID, err)
-		return fmt.Errorf("failed to set order in cache: %w", err)
+		metrics.CacheErrors.WithLabelValues("set").Inc()
+		c.logger.ErrorContext(ctx, "Failed to set order in cache", "order_uid", order.OrderUID, "error", err)
+		return 0, fmt.Errorf("failed to set order in cache: %w", err)
 	}
-	
-	log.Printf("Successfully cached order with ID: %s", order.OrderUID)
-	
+
+	c.logger.DebugContext(ctx, "Successfully cached order", "order_uid", order.OrderUID, "bytes", len(data), "ttl", ttl)
+
+	c.publishInvalidation(ctx, order.OrderUID)
+
+	return ttl, nil
+}
+
+// SetNotFound кеширует отсутствие заказа. Отметка лежит в отдельном ключе
+// и не затирает заказ, который успели сохранить и закешировать после
+// чтения из БД: Get отдаёт заказ, даже если отметка ещё не истекла
+func (c *RedisCache) SetNotFound(ctx context.Context, orderUID string, ttl time.Duration) (err error) {
+	ctx, end := startCommand(ctx, "set_not_found", orderUID)
+	defer func() { end(err) }()
+
+	if err := c.client.Set(ctx, notFoundKey(orderUID), notFoundMarker, ttl).Err(); err != nil {
+		metrics.CacheErrors.WithLabelValues("set_not_found").Inc()
+		c.logger.ErrorContext(ctx, "Failed to cache missing order", "order_uid", orderUID, "error", err)
+		return fmt.Errorf("failed to set not found marker in cache: %w", err)
+	}
+
+	c.logger.DebugContext(ctx, "Cached order as not found", "order_uid", orderUID, "ttl", ttl)
+
 	return nil
 }
 
-func (c *RedisCache) Count(ctx context.Context) (int64, error) {
-	return c.client.DBSize(ctx).Result()
-}
-
-func (c *RedisCache) GetOrderById(ctx context.Context, id string) (*model.Order, error) {
-	orderQuery := `
-        SELECT o.order_uid, o.track_number, o.entry, o.locale, 
-	         o.internal_signature, 
-             o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
-             d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
-             p.transaction, p.request_id, p.currency, p.provider, p.amount, 
-             p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee 
-       	FROM orders o
-		JOIN deliveries d ON o.order_uid = d.order_uid
-		JOIN payments p ON o.order_uid = p.order_uid
-		WHERE o.order_uid = $1
-	`
-	
-	itemsQuery := `
-	    SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		FROM items WHERE order_uid = $1
-	`
-
-	var order model.Order
-	var delivery model.Delivery
-	var payment model.Payment
-
-	var items []model.Item
-
-	err := c.db.QueryRowContext(ctx, orderQuery, id).Scan(
-		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-        &order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard,
-        &delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email,
-        &payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount,
-        &payment.PaymentDt, &payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+// skip удаляет из кеша заказ, который политика не кеширует: прежняя его
+// версия могла быть записана, пока заказ был меньше
+func (c *RedisCache) skip(ctx context.Context, order *model.Order, size int) error {
+	metrics.CacheSkipped.Inc()
+	c.logger.DebugContext(ctx, "Order exceeds cache size limits, not caching", "order_uid", order.OrderUID, "items", len(order.Items), "bytes", size)
+
+	if err := c.client.Del(ctx, orderKey(order.OrderUID), notFoundKey(order.OrderUID)).Err(); err != nil {
+		metrics.CacheErrors.WithLabelValues("set").Inc()
+		return fmt.Errorf("failed to delete uncacheable order from cache: %w", err)
 	}
 
-	order.Delivery = delivery
-	order.Payment = payment
+	c.publishInvalidation(ctx, order.OrderUID)
+	return nil
+}
 
-	rows, err := c.db.QueryContext(ctx, itemsQuery, id)
-	if err != nil {
-		return nil, err
+// touch продлевает время жизни прочитанного заказа. Ошибка не мешает
+// отдать заказ: он просто истечёт раньше
+func (c *RedisCache) touch(ctx context.Context, key string, order *model.Order) {
+	ttl := c.policy.Expiration(order, time.Now())
+	if err := c.client.Expire(ctx, key, ttl).Err(); err != nil {
+		metrics.CacheErrors.WithLabelValues("expire").Inc()
+		c.logger.ErrorContext(ctx, "Failed to extend order TTL in cache", "order_uid", order.OrderUID, "error", err)
 	}
-	defer rows.Close()
+}
 
-	for rows.Next() {
-		var item model.Item
-		err := rows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name,
-            &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status,
-		)
-		if err != nil {
-			return nil, err
-		}
-		items = append(items, item)
+// invalidation - сообщение канала инвалидации
+type invalidation struct {
+	Origin   string `json:"origin"`
+	OrderUID string `json:"order_uid"`
+}
+
+// publishInvalidation сообщает репликам, что заказ изменился. Ошибка
+// публикации не отменяет запись: реплики сбросят заказ по своему TTL
+func (c *RedisCache) publishInvalidation(ctx context.Context, orderUID string) {
+	if c.channel == "" {
+		return
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+
+	data, err := json.Marshal(invalidation{Origin: c.instanceID, OrderUID: orderUID})
+	if err != nil {
+		c.logger.ErrorContext(ctx, "Failed to marshal invalidation", "order_uid", orderUID, "error", err)
+		return
 	}
 
-	order.Items = items
+	if err := c.client.Publish(ctx, c.channel, data).Err(); err != nil {
+		c.logger.ErrorContext(ctx, "Failed to publish invalidation", "order_uid", orderUID, "error", err)
+	}
+}
 
-	return &order, nil
+// Stats возвращает статистику попаданий в Redis
+func (c *RedisCache) Stats() []domain.TierStats {
+	return []domain.TierStats{domain.NewTierStats("redis", c.hits.Load(), c.misses.Load())}
+}
+
+// Count возвращает число закешированных заказов. Считаются только ключи
+// order:*: отметки об отсутствии заказов, место остановки прогрева и
+// блокировка сверки лежат в той же базе Redis, но заказами не являются
+func (c *RedisCache) Count(ctx context.Context) (int64, error) {
+	var count int64
+	var cursor uint64
+	for {
+		keys, next, err := c.client.Scan(ctx, cursor, orderKeyPrefix+"*", countScanSize).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to count cached orders: %w", err)
+		}
+		count += int64(len(keys))
+
+		cursor = next
+		if cursor == 0 {
+			return count, nil
+		}
+	}
 }
 
 func (c *RedisCache) GetAllOrdersIDs(ctx context.Context) ([]string, error) {
@@ -172,43 +315,3 @@ func (c *RedisCache) GetAllOrdersIDs(ctx context.Context) ([]string, error) {
 
 	return orderIDs, nil
 }
-
-// Прелзагрузка из БД
-func (c *RedisCache) PreloadFromDatabase(ctx context.Context, batchSize int) error {
-	log.Printf("Starting cache preloading from db...")
-
-	if batchSize <= 0 {
-		return errors.New("invalid batch size")
-	}
-
-	orderIDs, err := c.GetAllOrdersIDs(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get order IDs: %w", err)
-	}
-
-	successCount := 0
-
-	for _, orderID := range orderIDs {
-		order, err := c.GetOrderById(ctx, orderID)
-		if err != nil {
-			log.Printf("Failed to get order %s: %v", orderID, err)
-			continue
-		}
-
-		if order == nil {
-			log.Printf("Order %s not found in db", orderID)
-			continue
-		}
-
-		if err := c.Set(ctx, order, time.Hour * 72); err != nil {
-			log.Printf("Failed to set order %s in cache: %v", orderID, err)
-			continue
-		}
-
-		successCount++ 
-	}
-
-	log.Printf("Cache preloading completed: %d/%d orders loaded", successCount, len(orderIDs))
-
-	return nil
-}
@@ -11,6 +11,7 @@ import (
 	redismock "github.com/go-redis/redismock/v9"
 	"github.com/stretchr/testify/require"
 
+	domaincache "github.com/sayhellolexa/order-service/internal/domain/cache"
 	model "github.com/sayhellolexa/order-service/internal/model"
 	"github.com/sayhellolexa/order-service/internal/repository/cache"
 )
@@ -33,7 +34,7 @@ func TestRedisCache_Get(t *testing.T) {
 		{
 			name: "cache hit",
 			mockFn: func() {
-				mock.ExpectGet("order:123").SetVal(string(data))
+				mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{string(data), nil})
 			},
 			wantErr: false,
 			wantNil: false,
@@ -41,15 +42,39 @@ func TestRedisCache_Get(t *testing.T) {
 		{
 			name: "cache miss",
 			mockFn: func() {
-				mock.ExpectGet("order:123").RedisNil()
+				mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{nil, nil})
 			},
 			wantErr: false,
 			wantNil: true,
 		},
+		{
+			name: "cached as not found",
+			mockFn: func() {
+				mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{nil, "not-found"})
+			},
+			wantErr: true,
+			wantNil: true,
+		},
+		{
+			name: "order wins over stale marker",
+			mockFn: func() {
+				mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{string(data), "not-found"})
+			},
+			wantErr: false,
+			wantNil: false,
+		},
+		{
+			name: "legacy marker in order key",
+			mockFn: func() {
+				mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{"not-found", nil})
+			},
+			wantErr: true,
+			wantNil: true,
+		},
 		{
 			name: "redis error",
 			mockFn: func() {
-				mock.ExpectGet("order:123").SetErr(errors.New("boom"))
+				mock.ExpectMGet("order:123", "notfound:123").SetErr(errors.New("boom"))
 			},
 			wantErr: true,
 			wantNil: true,
@@ -79,7 +104,7 @@ func TestRedisCache_Get(t *testing.T) {
 func TestRedisCache_Set(t *testing.T) {
 	rdb, mock := redismock.NewClientMock()
 	db, _, _ := sqlmock.New()
-	c := cache.NewRedisCacheRepository(rdb, db)
+	c := cache.NewRedisCacheRepository(rdb, db, cache.WithPolicy(domaincache.Policy{TTL: time.Hour}))
 
 
 	ctx := context.Background()
@@ -96,6 +121,7 @@ func TestRedisCache_Set(t *testing.T) {
 			name: "success",
 			mockFn: func() {
 			mock.ExpectSet("order:123", data, time.Hour).SetVal("OK")
+			mock.ExpectDel("notfound:123").SetVal(0)
 		},
 			wantErr: false,
 		},
@@ -103,6 +129,7 @@ func TestRedisCache_Set(t *testing.T) {
 			name: "redis error",
 			mockFn: func() {
 			mock.ExpectSet("order:123", data, time.Hour).SetErr(errors.New("boom"))
+			mock.ExpectDel("notfound:123").SetVal(0)
 		},
 			wantErr: true,
 		},
@@ -112,7 +139,7 @@ func TestRedisCache_Set(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt := tt
 			tt.mockFn()
-			err := c.Set(ctx, order, time.Hour)
+			err := c.Set(ctx, order)
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -121,4 +148,62 @@ func TestRedisCache_Set(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestRedisCache_SetNotFound(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	c := cache.NewRedisCacheRepository(rdb, db, cache.WithPolicy(domaincache.Policy{TTL: time.Hour}))
+
+	ctx := context.Background()
+
+	// Отметка лежит вне order:*, чтобы не считаться закешированным заказом
+	mock.ExpectSet("notfound:404", "not-found", time.Minute).SetVal("OK")
+	require.NoError(t, c.SetNotFound(ctx, "404", time.Minute))
+
+	mock.ExpectMGet("order:404", "notfound:404").SetVal([]any{nil, "not-found"})
+	got, err := c.Get(ctx, "404")
+	require.ErrorIs(t, err, domaincache.ErrNotFound)
+	require.Nil(t, got)
+
+	// Сохранённый заказ удаляет отметку
+	order := &model.Order{OrderUID: "404"}
+	data, _ := json.Marshal(order)
+	mock.ExpectSet("order:404", data, time.Hour).SetVal("OK")
+	mock.ExpectDel("notfound:404").SetVal(1)
+	require.NoError(t, c.Set(ctx, order))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisCache_Policy(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	c := cache.NewRedisCacheRepository(rdb, db, cache.WithPolicy(domaincache.Policy{
+		TTL:          time.Hour,
+		CompletedTTL: time.Minute,
+		Sliding:      true,
+		MaxItems:     1,
+	}))
+
+	ctx := context.Background()
+
+	// Время жизни зависит от заказа и продлевается при чтении
+	order := &model.Order{OrderUID: "123", Status: model.StatusDelivered}
+	data, _ := json.Marshal(order)
+	mock.ExpectSet("order:123", data, time.Minute).SetVal("OK")
+	mock.ExpectDel("notfound:123").SetVal(0)
+	require.NoError(t, c.Set(ctx, order))
+
+	mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{string(data), nil})
+	mock.ExpectExpire("order:123", time.Minute).SetVal(true)
+	got, err := c.Get(ctx, "123")
+	require.NoError(t, err)
+	require.Equal(t, "123", got.OrderUID)
+
+	// Слишком большой заказ не кешируется, а прежняя версия удаляется
+	large := &model.Order{OrderUID: "456", Items: make([]model.Item, 2)}
+	mock.ExpectDel("order:456", "notfound:456").SetVal(1)
+	require.NoError(t, c.Set(ctx, large))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
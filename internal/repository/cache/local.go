@@ -0,0 +1,305 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/cache"
+	"github.com/sayhellolexa/order-service/internal/metrics"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+const (
+	// Значения локального кеша по умолчанию
+	defaultLocalSize = 10000
+	defaultLocalTTL  = time.Minute
+
+	// Пауза перед повторной подпиской на канал инвалидации после ошибки
+	resubscribeDelay = time.Second
+)
+
+// LocalCache - ограниченный по размеру LRU-кеш в памяти процесса перед
+// RedisCache. Записи живут не дольше собственного TTL и сбрасываются по
+// сообщениям из канала инвалидации, когда заказ меняет другой процесс
+type LocalCache struct {
+	redis *RedisCache
+	size  int
+	ttl   time.Duration
+	// Сколько просроченная запись ещё может отдаваться через GetStale
+	stale time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // от недавно использованных к давно использованным
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type localEntry struct {
+	orderUID  string
+	order     *model.Order
+	expiresAt time.Time
+}
+
+// LocalOption настраивает необязательные параметры LocalCache
+type LocalOption func(*LocalCache)
+
+// WithLocalSize задаёт максимальное число заказов в памяти
+func WithLocalSize(size int) LocalOption {
+	return func(c *LocalCache) {
+		c.size = size
+	}
+}
+
+// WithLocalTTL задаёт, как долго заказ хранится в памяти
+func WithLocalTTL(ttl time.Duration) LocalOption {
+	return func(c *LocalCache) {
+		c.ttl = ttl
+	}
+}
+
+// WithStaleWhileRevalidate разрешает GetStale отдавать просроченную запись
+// ещё в течение d, пока вызывающий обновляет её в фоне. Просрочка касается
+// только копии в памяти: обновляется она из Redis через Get
+func WithStaleWhileRevalidate(d time.Duration) LocalOption {
+	return func(c *LocalCache) {
+		c.stale = d
+	}
+}
+
+func NewLocalCache(redisCache *RedisCache, opts ...LocalOption) *LocalCache {
+	c := &LocalCache{
+		redis:   redisCache,
+		size:    defaultLocalSize,
+		ttl:     defaultLocalTTL,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get ищет заказ в памяти, а при промахе - в Redis
+func (c *LocalCache) Get(ctx context.Context, orderUID string) (*model.Order, error) {
+	if order, ok := c.get(orderUID); ok {
+		c.hits.Add(1)
+		metrics.CacheRequests.WithLabelValues("local", "hit").Inc()
+		return order, nil
+	}
+	c.misses.Add(1)
+	metrics.CacheRequests.WithLabelValues("local", "miss").Inc()
+
+	return c.fill(ctx, orderUID)
+}
+
+// GetStale как Get, но при включённом stale-while-revalidate может вернуть
+// просроченную запись из памяти, сообщив об этом флагом stale
+func (c *LocalCache) GetStale(ctx context.Context, orderUID string) (*model.Order, bool, error) {
+	if order, fresh, ok := c.lookup(orderUID); ok {
+		c.hits.Add(1)
+		metrics.CacheRequests.WithLabelValues("local", "hit").Inc()
+		return order, !fresh, nil
+	}
+	c.misses.Add(1)
+	metrics.CacheRequests.WithLabelValues("local", "miss").Inc()
+
+	order, err := c.fill(ctx, orderUID)
+	return order, false, err
+}
+
+// fill читает заказ из Redis и запоминает его в памяти
+func (c *LocalCache) fill(ctx context.Context, orderUID string) (*model.Order, error) {
+	order, err := c.redis.Get(ctx, orderUID)
+	if err != nil || order == nil {
+		return order, err
+	}
+
+	c.put(order, c.ttl)
+	return order, nil
+}
+
+// Set записывает заказ в Redis, а затем в память. Запись в памяти
+// живёт не дольше записи в Redis, а заказ, который политика кеша не
+// кеширует, из памяти удаляется
+func (c *LocalCache) Set(ctx context.Context, order *model.Order) (err error) {
+	ctx, end := startCommand(ctx, "set", order.OrderUID)
+	defer func() { end(err) }()
+
+	ttl, err := c.redis.store(ctx, order)
+	if err != nil || ttl == 0 {
+		c.remove(order.OrderUID)
+		return err
+	}
+
+	c.put(order, min(ttl, c.ttl))
+	return nil
+}
+
+// SetNotFound хранит отметку только в Redis: в памяти отсутствующие заказы не кешируются
+func (c *LocalCache) SetNotFound(ctx context.Context, orderUID string, ttl time.Duration) error {
+	return c.redis.SetNotFound(ctx, orderUID, ttl)
+}
+
+func (c *LocalCache) Count(ctx context.Context) (int64, error) {
+	return c.redis.Count(ctx)
+}
+
+func (c *LocalCache) GetAllOrdersIDs(ctx context.Context) ([]string, error) {
+	return c.redis.GetAllOrdersIDs(ctx)
+}
+
+// PreloadFromDatabase прогревает Redis. Память заполняется по мере запросов
+func (c *LocalCache) PreloadFromDatabase(ctx context.Context, opts domain.PreloadOptions) error {
+	return c.redis.PreloadFromDatabase(ctx, opts)
+}
+
+// Reconcile сверяет Redis с БД. Сообщения об исправлениях от своего же
+// процесса LocalCache пропускает, поэтому после исправлений память очищается
+func (c *LocalCache) Reconcile(ctx context.Context, opts domain.ReconcileOptions) (*domain.ReconcileReport, error) {
+	report, err := c.redis.Reconcile(ctx, opts)
+	if report != nil && !report.DryRun && report.Fixed() > 0 {
+		c.purge()
+	}
+	return report, err
+}
+
+// Stats возвращает статистику попаданий по уровням: память, затем Redis
+func (c *LocalCache) Stats() []domain.TierStats {
+	local := domain.NewTierStats("local", c.hits.Load(), c.misses.Load())
+	return append([]domain.TierStats{local}, c.redis.Stats()...)
+}
+
+// Listen сбрасывает из памяти заказы, изменённые другими процессами,
+// пока не будет отменён ctx. Без канала инвалидации сразу возвращается.
+// После переподключения к Redis память очищается целиком: сообщения,
+// пришедшие во время обрыва, потеряны
+func (c *LocalCache) Listen(ctx context.Context) {
+	if c.redis.channel == "" {
+		return
+	}
+
+	pubsub := c.redis.client.Subscribe(ctx, c.redis.channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.redis.logger.ErrorContext(ctx, "Failed to receive cache invalidation", "error", err)
+			c.purge()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeDelay):
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			c.redis.logger.InfoContext(ctx, "Subscribed to cache invalidation channel", "channel", msg.Channel)
+			c.purge()
+		case *redis.Message:
+			c.invalidate(msg.Payload)
+		}
+	}
+}
+
+// invalidate сбрасывает заказ из сообщения об изменении, если его изменил другой процесс
+func (c *LocalCache) invalidate(payload string) {
+	var msg invalidation
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		c.redis.logger.Error("Failed to unmarshal cache invalidation", "error", err)
+		return
+	}
+
+	if msg.Origin == c.redis.instanceID {
+		return
+	}
+
+	c.remove(msg.OrderUID)
+}
+
+// get возвращает только свежую запись
+func (c *LocalCache) get(orderUID string) (*model.Order, bool) {
+	order, fresh, ok := c.lookup(orderUID)
+	return order, ok && fresh
+}
+
+// lookup возвращает запись и признак её свежести. Просроченная запись
+// хранится ещё stale, после чего удаляется
+func (c *LocalCache) lookup(orderUID string) (order *model.Order, fresh, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[orderUID]
+	if !ok {
+		return nil, false, false
+	}
+
+	entry := elem.Value.(*localEntry)
+	now := time.Now()
+	if now.After(entry.expiresAt.Add(c.stale)) {
+		c.order.Remove(elem)
+		delete(c.entries, orderUID)
+		return nil, false, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.order, !now.After(entry.expiresAt), true
+}
+
+func (c *LocalCache) put(order *model.Order, ttl time.Duration) {
+	if c.size <= 0 || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &localEntry{orderUID: order.OrderUID, order: order, expiresAt: time.Now().Add(ttl)}
+
+	if elem, ok := c.entries[order.OrderUID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[order.OrderUID] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*localEntry).orderUID)
+	}
+}
+
+func (c *LocalCache) remove(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[orderUID]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, orderUID)
+	}
+}
+
+func (c *LocalCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
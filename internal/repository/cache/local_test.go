@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	redismock "github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/cache"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func TestLocalCache_Get(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	c := NewLocalCache(NewRedisCacheRepository(rdb, db))
+
+	ctx := context.Background()
+	data, _ := json.Marshal(&model.Order{OrderUID: "123"})
+
+	// Второе чтение обслуживается из памяти, Redis запрашивается один раз
+	mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{string(data), nil})
+	mock.ExpectMGet("order:404", "notfound:404").SetVal([]any{nil, nil})
+
+	for range 2 {
+		order, err := c.Get(ctx, "123")
+		require.NoError(t, err)
+		require.NotNil(t, order)
+		assert.Equal(t, "123", order.OrderUID)
+	}
+
+	order, err := c.Get(ctx, "404")
+	require.NoError(t, err)
+	assert.Nil(t, order)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	stats := c.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "local", stats[0].Tier)
+	assert.Equal(t, uint64(1), stats[0].Hits)
+	assert.Equal(t, uint64(2), stats[0].Misses)
+	assert.Equal(t, "redis", stats[1].Tier)
+	assert.Equal(t, uint64(1), stats[1].Hits)
+	assert.Equal(t, uint64(1), stats[1].Misses)
+}
+
+func TestLocalCache_Set(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	redisCache := NewRedisCacheRepository(rdb, db, WithInvalidation(DefaultInvalidationChannel), WithPolicy(domain.Policy{TTL: time.Hour}))
+	c := NewLocalCache(redisCache)
+
+	ctx := context.Background()
+	order := &model.Order{OrderUID: "123"}
+	data, _ := json.Marshal(order)
+	message, _ := json.Marshal(invalidation{Origin: redisCache.instanceID, OrderUID: "123"})
+
+	mock.ExpectSet("order:123", data, time.Hour).SetVal("OK")
+	mock.ExpectDel("notfound:123").SetVal(0)
+	mock.ExpectPublish(DefaultInvalidationChannel, message).SetVal(1)
+
+	require.NoError(t, c.Set(ctx, order))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// Запись видна из памяти без обращения к Redis
+	cached, err := c.Get(ctx, "123")
+	require.NoError(t, err)
+	assert.Same(t, order, cached)
+}
+
+func TestLocalCache_Eviction(t *testing.T) {
+	rdb, _ := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	c := NewLocalCache(NewRedisCacheRepository(rdb, db), WithLocalSize(2), WithLocalTTL(time.Minute))
+
+	c.put(&model.Order{OrderUID: "1"}, time.Minute)
+	c.put(&model.Order{OrderUID: "2"}, time.Minute)
+	_, _ = c.get("1")
+	c.put(&model.Order{OrderUID: "3"}, time.Minute)
+
+	_, ok := c.get("2")
+	assert.False(t, ok, "least recently used entry must be evicted")
+	_, ok = c.get("1")
+	assert.True(t, ok)
+	_, ok = c.get("3")
+	assert.True(t, ok)
+
+	c.put(&model.Order{OrderUID: "4"}, -time.Second)
+	_, ok = c.get("4")
+	assert.False(t, ok, "expired entry must not be returned")
+}
+
+func TestLocalCache_Invalidate(t *testing.T) {
+	rdb, _ := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	c := NewLocalCache(NewRedisCacheRepository(rdb, db, WithInvalidation(DefaultInvalidationChannel)))
+
+	c.put(&model.Order{OrderUID: "123"}, time.Minute)
+
+	own, _ := json.Marshal(invalidation{Origin: c.redis.instanceID, OrderUID: "123"})
+	c.invalidate(string(own))
+	_, ok := c.get("123")
+	assert.True(t, ok, "own invalidation must be ignored")
+
+	other, _ := json.Marshal(invalidation{Origin: "other", OrderUID: "123"})
+	c.invalidate(string(other))
+	_, ok = c.get("123")
+	assert.False(t, ok)
+}
+
+func TestLocalCache_GetStale(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	c := NewLocalCache(NewRedisCacheRepository(rdb, db), WithStaleWhileRevalidate(time.Minute))
+
+	ctx := context.Background()
+	order := &model.Order{OrderUID: "123"}
+	c.put(order, time.Minute)
+
+	cached, stale, err := c.GetStale(ctx, "123")
+	require.NoError(t, err)
+	assert.Same(t, order, cached)
+	assert.False(t, stale)
+
+	// Запись просрочена, но ещё в пределах окна stale-while-revalidate
+	c.entries["123"].Value.(*localEntry).expiresAt = time.Now().Add(-time.Second)
+
+	cached, stale, err = c.GetStale(ctx, "123")
+	require.NoError(t, err)
+	assert.Same(t, order, cached)
+	assert.True(t, stale)
+
+	// Обычный Get просроченную запись не отдаёт и идёт в Redis
+	mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{nil, nil})
+	cached, err = c.Get(ctx, "123")
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// За пределами окна запись удаляется
+	c.entries["123"].Value.(*localEntry).expiresAt = time.Now().Add(-2 * time.Minute)
+	mock.ExpectMGet("order:123", "notfound:123").SetVal([]any{nil, nil})
+	cached, stale, err = c.GetStale(ctx, "123")
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+	assert.False(t, stale)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,539 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/errgroup"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/cache"
+	"github.com/sayhellolexa/order-service/internal/metrics"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+const (
+	// Значения прогрева по умолчанию
+	defaultPreloadBatchSize   = 100
+	defaultPreloadConcurrency = 4
+	defaultProgressInterval   = 5 * time.Second
+
+	// Ключ, в котором хранится место остановки прерванного прогрева
+	preloadCheckpointKey = "preload:checkpoint"
+	preloadCheckpointTTL = 24 * time.Hour
+)
+
+// preloadCursor - последний заказ обработанной части в порядке прогрева
+type preloadCursor struct {
+	DateCreated time.Time `json:"date_created"`
+	OrderUID    string    `json:"order_uid"`
+}
+
+// preloadCheckpoint - состояние прогрева. Filter описывает ограничения
+// прогрева: продолжить можно только прогрев с теми же ограничениями
+type preloadCheckpoint struct {
+	Filter  string         `json:"filter"`
+	After   *preloadCursor `json:"after,omitempty"`
+	Scanned int            `json:"scanned"`
+	Loaded  int            `json:"loaded"`
+}
+
+// preloadBatch - пакет order_uid в порядке прогрева
+type preloadBatch struct {
+	seq  int
+	uids []string
+	last preloadCursor
+}
+
+type preloadResult struct {
+	batch  preloadBatch
+	loaded int
+}
+
+// PreloadFromDatabase прогревает Redis заказами из БД, от новых к старым.
+// Order_uid читаются постранично, каждый пакет загружается одним запросом
+// вместе с товарами и пишется в Redis одним пайплайном; пакеты загружаются
+// параллельно. После каждого пакета сохраняется место остановки, так что
+// прерванный прогрев с теми же ограничениями продолжается, а не начинается заново
+func (c *RedisCache) PreloadFromDatabase(ctx context.Context, opts domain.PreloadOptions) error {
+	opts = withPreloadDefaults(opts)
+	if opts.BatchSize <= 0 || opts.Concurrency <= 0 {
+		return errors.New("invalid batch size or concurrency")
+	}
+
+	filter := preloadFilter(opts)
+
+	checkpoint, err := c.loadCheckpoint(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if checkpoint == nil {
+		if opts.IfEmpty {
+			count, err := c.Count(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to check cache: %w", err)
+			}
+			if count > 0 {
+				c.logger.InfoContext(ctx, "Cache is not empty, preload skipped", "records", count)
+				return nil
+			}
+		}
+		checkpoint = &preloadCheckpoint{Filter: filter}
+		c.logger.InfoContext(ctx, "Starting cache preloading from db")
+	} else {
+		c.logger.InfoContext(ctx, "Resuming cache preloading from db", "scanned", checkpoint.Scanned)
+	}
+
+	total, err := c.countPreload(ctx, opts, checkpoint)
+	if err != nil {
+		return err
+	}
+
+	progress := newPreloadProgress(total, c.logger)
+	stopProgress := progress.report(opts.ProgressInterval)
+	defer stopProgress()
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	batches := make(chan preloadBatch)
+	g.Go(func() error {
+		defer close(batches)
+		return c.streamPreload(gctx, opts, checkpoint, batches)
+	})
+
+	results := make(chan preloadResult)
+	var workers sync.WaitGroup
+	for range opts.Concurrency {
+		workers.Add(1)
+		g.Go(func() error {
+			defer workers.Done()
+			for batch := range batches {
+				loaded, err := c.preloadBatch(gctx, batch.uids)
+				if err != nil {
+					return err
+				}
+				progress.add(len(batch.uids), loaded)
+
+				select {
+				case results <- preloadResult{batch: batch, loaded: loaded}:
+				case <-gctx.Done():
+					return gctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	// Пакеты завершаются не по порядку: место остановки сдвигается только
+	// по непрерывной цепочке завершённых пакетов
+	done := make(map[int]preloadResult)
+	next := 0
+	for result := range results {
+		done[result.batch.seq] = result
+		advanced := false
+		for r, ok := done[next]; ok; r, ok = done[next] {
+			delete(done, next)
+			last := r.batch.last
+			checkpoint.After = &last
+			checkpoint.Scanned += len(r.batch.uids)
+			checkpoint.Loaded += r.loaded
+			next++
+			advanced = true
+		}
+		if advanced {
+			c.saveCheckpoint(ctx, checkpoint)
+		}
+	}
+
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("cache preloading interrupted after %d orders, it will be resumed: %w", checkpoint.Scanned, err)
+	}
+
+	if err := c.client.Del(ctx, preloadCheckpointKey).Err(); err != nil {
+		c.logger.ErrorContext(ctx, "Failed to delete preload checkpoint", "error", err)
+	}
+
+	c.logger.InfoContext(ctx, "Cache preloading completed", "loaded", checkpoint.Loaded, "scanned", checkpoint.Scanned)
+
+	return nil
+}
+
+func withPreloadDefaults(opts domain.PreloadOptions) domain.PreloadOptions {
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultPreloadBatchSize
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = defaultPreloadConcurrency
+	}
+	if opts.ProgressInterval == 0 {
+		opts.ProgressInterval = defaultProgressInterval
+	}
+	return opts
+}
+
+// preloadFilter описывает ограничения прогрева для сравнения с сохранённым состоянием
+func preloadFilter(opts domain.PreloadOptions) string {
+	var from, to string
+	if !opts.CreatedFrom.IsZero() {
+		from = opts.CreatedFrom.UTC().Format(time.RFC3339Nano)
+	}
+	if !opts.CreatedTo.IsZero() {
+		to = opts.CreatedTo.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("limit=%d from=%s to=%s", opts.Limit, from, to)
+}
+
+// loadCheckpoint возвращает место остановки прерванного прогрева
+// с теми же ограничениями или nil
+func (c *RedisCache) loadCheckpoint(ctx context.Context, filter string) (*preloadCheckpoint, error) {
+	data, err := c.client.Get(ctx, preloadCheckpointKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preload checkpoint: %w", err)
+	}
+
+	var checkpoint preloadCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		c.logger.WarnContext(ctx, "Failed to unmarshal preload checkpoint, starting over", "error", err)
+		return nil, nil
+	}
+
+	if checkpoint.Filter != filter {
+		c.logger.InfoContext(ctx, "Preload checkpoint was saved with other limits, starting over", "filter", checkpoint.Filter)
+		return nil, nil
+	}
+
+	return &checkpoint, nil
+}
+
+// saveCheckpoint сохраняет место остановки. Ошибка не прерывает прогрев:
+// в худшем случае часть заказов будет загружена повторно
+func (c *RedisCache) saveCheckpoint(ctx context.Context, checkpoint *preloadCheckpoint) {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "Failed to marshal preload checkpoint", "error", err)
+		return
+	}
+
+	if err := c.client.Set(ctx, preloadCheckpointKey, data, preloadCheckpointTTL).Err(); err != nil {
+		c.logger.ErrorContext(ctx, "Failed to save preload checkpoint", "error", err)
+	}
+}
+
+// preloadConditions строит условия выборки заказов для прогрева после курсора
+func preloadConditions(opts domain.PreloadOptions, after *preloadCursor) (string, []any) {
+	var conds []string
+	var args []any
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !opts.CreatedFrom.IsZero() {
+		conds = append(conds, "date_created >= "+arg(opts.CreatedFrom))
+	}
+	if !opts.CreatedTo.IsZero() {
+		conds = append(conds, "date_created < "+arg(opts.CreatedTo))
+	}
+	if after != nil {
+		conds = append(conds, fmt.Sprintf("(date_created, order_uid) < (%s, %s)",
+			arg(after.DateCreated), arg(after.OrderUID)))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// countPreload считает, сколько заказов осталось прогреть
+func (c *RedisCache) countPreload(ctx context.Context, opts domain.PreloadOptions, checkpoint *preloadCheckpoint) (int, error) {
+	where, args := preloadConditions(opts, checkpoint.After)
+
+	var total int
+	if err := c.db.QueryRowContext(ctx, `SELECT count(*) FROM orders`+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count orders for preload: %w", err)
+	}
+
+	if opts.Limit > 0 {
+		total = min(total, max(opts.Limit-checkpoint.Scanned, 0))
+	}
+	return total, nil
+}
+
+// streamPreload читает order_uid постранично по ключу (date_created, order_uid)
+// и отдаёт их пакетами, не загружая весь список в память
+func (c *RedisCache) streamPreload(ctx context.Context, opts domain.PreloadOptions, checkpoint *preloadCheckpoint, batches chan<- preloadBatch) error {
+	after := checkpoint.After
+	remaining := -1
+	if opts.Limit > 0 {
+		remaining = opts.Limit - checkpoint.Scanned
+	}
+
+	for seq := 0; remaining != 0; seq++ {
+		size := opts.BatchSize
+		if remaining > 0 {
+			size = min(size, remaining)
+		}
+
+		where, args := preloadConditions(opts, after)
+		args = append(args, size)
+		query := `SELECT order_uid, date_created FROM orders` + where +
+			fmt.Sprintf(" ORDER BY date_created DESC, order_uid DESC LIMIT $%d", len(args))
+
+		batch, err := c.readPreloadBatch(ctx, query, args)
+		if err != nil {
+			return err
+		}
+		if len(batch.uids) == 0 {
+			return nil
+		}
+
+		batch.seq = seq
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if len(batch.uids) < size {
+			return nil
+		}
+		if remaining > 0 {
+			remaining -= len(batch.uids)
+		}
+		last := batch.last
+		after = &last
+	}
+
+	return nil
+}
+
+func (c *RedisCache) readPreloadBatch(ctx context.Context, query string, args []any) (preloadBatch, error) {
+	var batch preloadBatch
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return batch, fmt.Errorf("failed to get orders IDs for preload: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&batch.last.OrderUID, &batch.last.DateCreated); err != nil {
+			return batch, fmt.Errorf("failed to scan orders IDs for preload: %w", err)
+		}
+		batch.uids = append(batch.uids, batch.last.OrderUID)
+	}
+
+	return batch, rows.Err()
+}
+
+// ordersQuery выбирает заказы пакета вместе с товарами, собранными в JSON
+const ordersQuery = `
+	SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+		o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status,
+		d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+		p.transaction, p.request_id, p.currency, p.provider, p.amount,
+		p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
+		COALESCE((
+			SELECT json_agg(json_build_object(
+				'chrt_id', i.chrt_id, 'track_number', i.track_number, 'price', i.price,
+				'rid', i.rid, 'name', i.name, 'sale', i.sale, 'size', i.size,
+				'total_price', i.total_price, 'nm_id', i.nm_id, 'brand', i.brand, 'status', i.status
+			) ORDER BY i.id)
+			FROM items i WHERE i.order_uid = o.order_uid
+		), '[]')
+	FROM orders o
+	JOIN deliveries d ON o.order_uid = d.order_uid
+	JOIN payments p ON o.order_uid = p.order_uid
+	WHERE o.order_uid = ANY($1)`
+
+// preloadBatch загружает заказы пакета одним запросом и пишет их в Redis
+// одним пайплайном с временем жизни по политике кеша. Заказы, которые
+// политика не кеширует, пропускаются. Запись не затирает заказ, который
+// успели закешировать после чтения из БД, и, как store, удаляет отметку
+// об отсутствии заказа и сообщает репликам о новых записях. Возвращает
+// число закешированных заказов
+func (c *RedisCache) preloadBatch(ctx context.Context, uids []string) (int, error) {
+	orders, err := c.loadOrders(ctx, uids)
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := c.client.Pipeline()
+	written := make(map[string]*redis.BoolCmd, len(orders))
+	now := time.Now()
+
+	for _, order := range orders {
+		data, err := json.Marshal(order)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "Failed to marshal order", "order_uid", order.OrderUID, "error", err)
+			continue
+		}
+
+		if !c.policy.Cacheable(order, len(data)) {
+			metrics.CacheSkipped.Inc()
+			continue
+		}
+
+		written[order.OrderUID] = pipe.SetNX(ctx, orderKey(order.OrderUID), data, c.policy.Expiration(order, now))
+		pipe.Del(ctx, notFoundKey(order.OrderUID))
+	}
+
+	if len(written) == 0 {
+		return 0, nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to write orders to cache: %w", err)
+	}
+
+	for _, order := range orders {
+		if cmd, ok := written[order.OrderUID]; ok && cmd.Val() {
+			c.publishInvalidation(ctx, order.OrderUID)
+		}
+	}
+
+	return len(written), nil
+}
+
+// loadOrders загружает заказы вместе с товарами одним запросом.
+// Заказов, которых нет в БД, в результате нет
+func (c *RedisCache) loadOrders(ctx context.Context, uids []string) ([]*model.Order, error) {
+	rows, err := c.db.QueryContext(ctx, ordersQuery, uids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*model.Order
+	for rows.Next() {
+		var order model.Order
+		var items []byte
+
+		err := rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+			&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Status,
+			&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
+			&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email,
+			&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
+			&order.Payment.Amount, &order.Payment.PaymentDt, &order.Payment.Bank, &order.Payment.DeliveryCost,
+			&order.Payment.GoodsTotal, &order.Payment.CustomFee,
+			&items,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+
+		if err := json.Unmarshal(items, &order.Items); err != nil {
+			c.logger.ErrorContext(ctx, "Failed to unmarshal order items", "order_uid", order.OrderUID, "error", err)
+			continue
+		}
+		// Как и при чтении из БД, заказ без товаров хранится с items: null
+		if len(order.Items) == 0 {
+			order.Items = nil
+		}
+
+		orders = append(orders, &order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// preloadProgress считает обработанные заказы и периодически сообщает
+// о прогрессе и оставшемся времени
+type preloadProgress struct {
+	total   int
+	started time.Time
+	scanned atomic.Int64
+	loaded  atomic.Int64
+	logger  *slog.Logger
+}
+
+func newPreloadProgress(total int, logger *slog.Logger) *preloadProgress {
+	metrics.PreloadOrders.WithLabelValues("total").Set(float64(total))
+	metrics.PreloadOrders.WithLabelValues("scanned").Set(0)
+	metrics.PreloadOrders.WithLabelValues("loaded").Set(0)
+	return &preloadProgress{total: total, started: time.Now(), logger: logger}
+}
+
+func (p *preloadProgress) add(scanned, loaded int) {
+	p.scanned.Add(int64(scanned))
+	p.loaded.Add(int64(loaded))
+	metrics.PreloadOrders.WithLabelValues("scanned").Add(float64(scanned))
+	metrics.PreloadOrders.WithLabelValues("loaded").Add(float64(loaded))
+}
+
+// report запускает периодический вывод прогресса и возвращает функцию остановки
+func (p *preloadProgress) report(interval time.Duration) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.log()
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// log сообщает о прогрессе и оставшемся времени
+func (p *preloadProgress) log() {
+	scanned := int(p.scanned.Load())
+	elapsed := time.Since(p.started)
+
+	percent := 100.0
+	if p.total > 0 {
+		percent = float64(scanned) * 100 / float64(p.total)
+	}
+
+	eta := "unknown"
+	if scanned > 0 {
+		rate := float64(scanned) / elapsed.Seconds()
+		remaining := time.Duration(float64(max(p.total-scanned, 0)) / rate * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+
+	p.logger.Info("Cache preloading",
+		"scanned", scanned,
+		"total", p.total,
+		"percent", fmt.Sprintf("%.1f", percent),
+		"loaded", p.loaded.Load(),
+		"elapsed", elapsed.Round(time.Second).String(),
+		"eta", eta,
+	)
+}
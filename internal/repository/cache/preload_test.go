@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	redismock "github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/cache"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// anyValueConverter пропускает срезы в аргументы запроса, как это делает драйвер pgx
+type anyValueConverter struct{}
+
+func (anyValueConverter) ConvertValue(v any) (driver.Value, error) {
+	return v, nil
+}
+
+var preloadOrderColumns = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature",
+	"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status",
+	"name", "phone", "zip", "city", "address", "region", "email",
+	"transaction", "request_id", "currency", "provider", "amount",
+	"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee", "items",
+}
+
+func preloadOrderRow(rows *sqlmock.Rows, uid string, created time.Time, items string) *sqlmock.Rows {
+	return rows.AddRow(
+		uid, "TRACK", "WBIL", "ru", "", "cust", "meest", "9", 99, created, "1", "created",
+		"John", "+9720012345", "123", "City", "Street", "Region", "john@example.com",
+		"tx", "", "USD", "wbpay", 100, 1637907727, "alpha", 50, 50, 0, []byte(items),
+	)
+}
+
+func TestRedisCache_PreloadFromDatabase(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, sqlMock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	c := NewRedisCacheRepository(rdb, db, WithPolicy(domain.Policy{TTL: time.Hour}), WithInvalidation(DefaultInvalidationChannel))
+	ctx := context.Background()
+
+	newer := time.Date(2025, 8, 26, 12, 0, 0, 0, time.UTC)
+	older := newer.Add(-time.Hour)
+
+	mock.ExpectGet(preloadCheckpointKey).RedisNil()
+	mock.ExpectScan(0, "order:*", countScanSize).SetVal(nil, 0)
+
+	sqlMock.ExpectQuery(`SELECT count\(\*\) FROM orders`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	// Ограничение в 2 заказа укладывается в один пакет
+	sqlMock.ExpectQuery(`SELECT order_uid, date_created FROM orders ORDER BY date_created DESC, order_uid DESC LIMIT \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "date_created"}).
+			AddRow("2", newer).AddRow("1", older))
+
+	orderRows := sqlmock.NewRows(preloadOrderColumns)
+	preloadOrderRow(orderRows, "2", newer, `[{"chrt_id": 1, "name": "item", "price": 50, "total_price": 50}]`)
+	preloadOrderRow(orderRows, "1", older, `[]`)
+	sqlMock.ExpectQuery(`FROM orders o`).WithArgs([]string{"2", "1"}).WillReturnRows(orderRows)
+
+	expected := make(map[string][]byte)
+	for _, uid := range []string{"2", "1"} {
+		order := model.Order{
+			OrderUID: uid, TrackNumber: "TRACK", Entry: "WBIL", Locale: "ru", CustomerID: "cust",
+			DeliveryService: "meest", ShardKey: "9", SmID: 99, DateCreated: newer, OofShard: "1",
+			Status: model.StatusCreated,
+			Delivery: model.Delivery{Name: "John", Phone: "+9720012345", Zip: "123", City: "City",
+				Address: "Street", Region: "Region", Email: "john@example.com"},
+			Payment: model.Payment{Transaction: "tx", Currency: "USD", Provider: "wbpay", Amount: 100 * model.Unit,
+				PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 50 * model.Unit, GoodsTotal: 50 * model.Unit},
+		}
+		if uid == "2" {
+			order.Items = []model.Item{{ChrtID: 1, Name: "item", Price: 50 * model.Unit, TotalPrice: 50 * model.Unit}}
+		} else {
+			order.DateCreated = older
+		}
+		expected[uid], _ = json.Marshal(order)
+	}
+
+	// Заказ "1" успели закешировать после чтения из БД: прогрев его не
+	// затирает и не сообщает о нём репликам
+	mock.ExpectSetNX("order:2", expected["2"], time.Hour).SetVal(true)
+	mock.ExpectDel("notfound:2").SetVal(1)
+	mock.ExpectSetNX("order:1", expected["1"], time.Hour).SetVal(false)
+	mock.ExpectDel("notfound:1").SetVal(0)
+	publish, _ := json.Marshal(invalidation{Origin: c.instanceID, OrderUID: "2"})
+	mock.ExpectPublish(DefaultInvalidationChannel, publish).SetVal(1)
+
+	checkpoint, _ := json.Marshal(preloadCheckpoint{
+		Filter:  preloadFilter(domain.PreloadOptions{Limit: 2}),
+		After:   &preloadCursor{DateCreated: older, OrderUID: "1"},
+		Scanned: 2,
+		Loaded:  2,
+	})
+	mock.ExpectSet(preloadCheckpointKey, checkpoint, preloadCheckpointTTL).SetVal("OK")
+	mock.ExpectDel(preloadCheckpointKey).SetVal(1)
+
+	err = c.PreloadFromDatabase(ctx, domain.PreloadOptions{
+		BatchSize:   10,
+		Concurrency: 1,
+		Limit:       2,
+		IfEmpty:     true,
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisCache_PreloadFromDatabase_Resume(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, sqlMock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	c := NewRedisCacheRepository(rdb, db)
+	ctx := context.Background()
+
+	after := preloadCursor{DateCreated: time.Date(2025, 8, 26, 12, 0, 0, 0, time.UTC), OrderUID: "5"}
+	checkpoint, _ := json.Marshal(preloadCheckpoint{
+		Filter:  preloadFilter(domain.PreloadOptions{}),
+		After:   &after,
+		Scanned: 5,
+		Loaded:  5,
+	})
+
+	// Прерванный прогрев продолжается, хотя кеш не пуст
+	mock.ExpectGet(preloadCheckpointKey).SetVal(string(checkpoint))
+
+	sqlMock.ExpectQuery(`SELECT count\(\*\) FROM orders WHERE \(date_created, order_uid\) < \(\$1, \$2\)`).
+		WithArgs(after.DateCreated, after.OrderUID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery(`SELECT order_uid, date_created FROM orders WHERE \(date_created, order_uid\) < \(\$1, \$2\)`).
+		WithArgs(after.DateCreated, after.OrderUID, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "date_created"}))
+
+	mock.ExpectDel(preloadCheckpointKey).SetVal(1)
+
+	err = c.PreloadFromDatabase(ctx, domain.PreloadOptions{BatchSize: 10, IfEmpty: true})
+	require.NoError(t, err)
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisCache_PreloadFromDatabase_IfEmpty(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, sqlMock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	c := NewRedisCacheRepository(rdb, db)
+	ctx := context.Background()
+
+	// В базе Redis есть только отметка notfound:* и служебные ключи: SCAN
+	// по order:* их не возвращает, и кеш считается пустым
+	mock.ExpectGet(preloadCheckpointKey).RedisNil()
+	mock.ExpectScan(0, "order:*", countScanSize).SetVal(nil, 17)
+	mock.ExpectScan(17, "order:*", countScanSize).SetVal(nil, 0)
+
+	sqlMock.ExpectQuery(`SELECT count\(\*\) FROM orders`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery(`SELECT order_uid, date_created FROM orders ORDER BY date_created DESC, order_uid DESC LIMIT \$1`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"order_uid", "date_created"}))
+	mock.ExpectDel(preloadCheckpointKey).SetVal(0)
+
+	require.NoError(t, c.PreloadFromDatabase(ctx, domain.PreloadOptions{BatchSize: 10, IfEmpty: true}))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Закешированный заказ отменяет прогрев
+	mock.ExpectGet(preloadCheckpointKey).RedisNil()
+	mock.ExpectScan(0, "order:*", countScanSize).SetVal([]string{"order:1"}, 0)
+
+	require.NoError(t, c.PreloadFromDatabase(ctx, domain.PreloadOptions{BatchSize: 10, IfEmpty: true}))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
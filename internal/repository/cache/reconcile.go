@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/cache"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+const (
+	defaultReconcileBatchSize = 500
+
+	// Сколько order_uid исправленных записей попадает в отчёт
+	maxReportedUIDs = 100
+
+	// Блокировка не даёт репликам сверять кеш одновременно
+	reconcileLockKey = "reconcile:lock"
+	reconcileLockTTL = 30 * time.Minute
+)
+
+// ErrReconcileRunning возвращается, если сверку уже выполняет другой процесс
+var ErrReconcileRunning = errors.New("cache reconciliation is already running")
+
+// replaceIfUnchanged перезаписывает ключ на ARGV[3] миллисекунд, только если
+// его значение не изменилось с момента чтения. Пустое новое значение удаляет ключ
+var replaceIfUnchanged = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "" then
+	redis.call("DEL", KEYS[1])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+end
+return 1
+`)
+
+// releaseLock снимает блокировку, только если её держит этот процесс
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Reconcile сверяет записи order:* и отметки notfound:* в Redis с заказами
+// в БД. Ключи обходятся через SCAN пакетами, заказы пакета читаются из БД
+// одним запросом и сравниваются по хешу содержимого. Устаревшие записи
+// перезаписываются заказом из БД, записи удалённых заказов, битые записи и
+// отметки о заказах, которые уже есть в БД, удаляются. Исправления следуют
+// политике кеша: заказ записывается с TTL по политике, а заказ, который она
+// не кеширует, удаляется. Записи, изменённые во время сверки, не трогаются
+func (c *RedisCache) Reconcile(ctx context.Context, opts domain.ReconcileOptions) (*domain.ReconcileReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultReconcileBatchSize
+	}
+
+	acquired, err := c.client.SetNX(ctx, reconcileLockKey, c.instanceID, reconcileLockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire reconcile lock: %w", err)
+	}
+	if !acquired {
+		return nil, ErrReconcileRunning
+	}
+	defer func() {
+		if err := releaseLock.Run(context.WithoutCancel(ctx), c.client, []string{reconcileLockKey}, c.instanceID).Err(); err != nil {
+			c.logger.ErrorContext(ctx, "Failed to release reconcile lock", "error", err)
+		}
+	}()
+
+	report := &domain.ReconcileReport{StartedAt: time.Now(), DryRun: opts.DryRun}
+
+	for _, prefix := range []string{orderKeyPrefix, notFoundKeyPrefix} {
+		var cursor uint64
+		for {
+			keys, next, err := c.client.Scan(ctx, cursor, prefix+"*", int64(opts.BatchSize)).Result()
+			if err != nil {
+				return report, fmt.Errorf("failed to scan cache: %w", err)
+			}
+
+			if len(keys) > 0 {
+				if err := c.reconcileBatch(ctx, prefix, keys, opts, report); err != nil {
+					return report, err
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+
+	report.Duration = time.Since(report.StartedAt)
+
+	c.logger.InfoContext(ctx, "Cache reconciliation completed",
+		"scanned", report.Scanned,
+		"matched", report.Matched,
+		"repaired", report.Repaired,
+		"orphans", report.Orphans,
+		"stale_markers", report.StaleMarkers,
+		"corrupted", report.Corrupted,
+		"uncacheable", report.Uncacheable,
+		"skipped", report.Skipped,
+	)
+
+	return report, nil
+}
+
+// reconcileBatch сверяет один пакет ключей с префиксом prefix
+func (c *RedisCache) reconcileBatch(ctx context.Context, prefix string, keys []string, opts domain.ReconcileOptions, report *domain.ReconcileReport) error {
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get cached orders: %w", err)
+	}
+
+	uids := make([]string, len(keys))
+	for i, key := range keys {
+		uids[i] = strings.TrimPrefix(key, prefix)
+	}
+
+	orders, err := c.loadOrders(ctx, uids)
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[string]*model.Order, len(orders))
+	for _, order := range orders {
+		stored[order.OrderUID] = order
+	}
+
+	for i, key := range keys {
+		cached, ok := values[i].(string)
+		if !ok {
+			// Ключ истёк или удалён между SCAN и MGET
+			continue
+		}
+		report.Scanned++
+
+		uid := uids[i]
+		order := stored[uid]
+
+		var replacement string
+		var ttl time.Duration
+		switch {
+		case cached == notFoundMarker:
+			if order == nil {
+				report.Matched++
+				continue
+			}
+			report.StaleMarkers++
+		case order == nil:
+			report.Orphans++
+		default:
+			data, err := json.Marshal(order)
+			if err != nil {
+				return fmt.Errorf("failed to marshal order %s: %w", uid, err)
+			}
+			if !c.policy.Cacheable(order, len(data)) {
+				report.Uncacheable++
+				break
+			}
+
+			cachedHash, err := contentHash([]byte(cached))
+			if err != nil {
+				report.Corrupted++
+				break
+			}
+
+			storedHash, err := contentHash(data)
+			if err != nil {
+				return err
+			}
+
+			if cachedHash == storedHash {
+				report.Matched++
+				continue
+			}
+			report.Repaired++
+			replacement = string(data)
+			ttl = c.policy.Expiration(order, time.Now())
+		}
+
+		if len(report.Mismatched) < maxReportedUIDs {
+			report.Mismatched = append(report.Mismatched, uid)
+		}
+
+		if opts.DryRun {
+			continue
+		}
+
+		replaced, err := replaceIfUnchanged.Run(ctx, c.client, []string{key}, cached, replacement, ttl.Milliseconds()).Int()
+		if err != nil {
+			return fmt.Errorf("failed to repair cached order %s: %w", uid, err)
+		}
+		if replaced == 0 {
+			report.Skipped++
+			continue
+		}
+
+		c.publishInvalidation(ctx, uid)
+	}
+
+	return nil
+}
+
+// contentHash считает хеш заказа после повторной сериализации, чтобы
+// форматирование JSON, часовой пояс даты и пустой список товаров
+// не влияли на сравнение
+func contentHash(data []byte) (string, error) {
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return "", fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+	order.DateCreated = order.DateCreated.UTC()
+	if len(order.Items) == 0 {
+		order.Items = nil
+	}
+
+	normalized, err := json.Marshal(order)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	sum := sha256.Sum256(normalized)
+	return string(sum[:]), nil
+}
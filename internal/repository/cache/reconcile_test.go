@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	redismock "github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/cache"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func TestRedisCache_Reconcile(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, sqlMock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	c := NewRedisCacheRepository(rdb, db, WithInvalidation(DefaultInvalidationChannel), WithPolicy(domain.Policy{TTL: time.Hour}))
+	ctx := context.Background()
+
+	created := time.Date(2025, 8, 26, 12, 0, 0, 0, time.UTC)
+	stored := func(uid string) *model.Order {
+		return &model.Order{
+			OrderUID: uid, TrackNumber: "TRACK", Entry: "WBIL", Locale: "ru", CustomerID: "cust",
+			DeliveryService: "meest", ShardKey: "9", SmID: 99, DateCreated: created, OofShard: "1",
+			Status: model.StatusCreated,
+			Delivery: model.Delivery{Name: "John", Phone: "+9720012345", Zip: "123", City: "City",
+				Address: "Street", Region: "Region", Email: "john@example.com"},
+			Payment: model.Payment{Transaction: "tx", Currency: "USD", Provider: "wbpay", Amount: 100 * model.Unit,
+				PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 50 * model.Unit, GoodsTotal: 50 * model.Unit},
+		}
+	}
+
+	// "1" совпадает с БД, хотя дата записана в другом часовом поясе, а
+	// товары - пустым списком,
+	// "2" устарел, "3" удалён из БД, "4" отмечен отсутствующим, но уже есть
+	// в БД, "5" есть в БД, но не разбирается, "6" истёк между SCAN и MGET,
+	// "7" отмечен отсутствующим и в БД его нет
+	same := stored("1")
+	same.DateCreated = created.In(time.FixedZone("MSK", 3*60*60))
+	same.Items = []model.Item{}
+	sameData, _ := json.Marshal(same)
+
+	outdated := stored("2")
+	outdated.Status = model.StatusPaid
+	outdatedData, _ := json.Marshal(outdated)
+	repairedData, _ := json.Marshal(stored("2"))
+
+	orphanData, _ := json.Marshal(stored("3"))
+
+	keys := []string{"order:1", "order:2", "order:3", "order:5", "order:6"}
+
+	mock.ExpectSetNX(reconcileLockKey, c.instanceID, reconcileLockTTL).SetVal(true)
+	mock.ExpectScan(0, "order:*", 10).SetVal(keys, 0)
+	mock.ExpectMGet(keys...).SetVal([]any{
+		string(sameData), string(outdatedData), string(orphanData), "{", nil,
+	})
+
+	orderRows := sqlmock.NewRows(preloadOrderColumns)
+	for _, uid := range []string{"1", "2", "5"} {
+		preloadOrderRow(orderRows, uid, created, `[]`)
+	}
+	sqlMock.ExpectQuery(`FROM orders o`).
+		WithArgs([]string{"1", "2", "3", "5", "6"}).
+		WillReturnRows(orderRows)
+
+	publish := func(uid string) {
+		message, _ := json.Marshal(invalidation{Origin: c.instanceID, OrderUID: uid})
+		mock.ExpectPublish(DefaultInvalidationChannel, message).SetVal(1)
+	}
+
+	// Исправленная запись получает TTL по политике, а не прежний
+	mock.ExpectEvalSha(replaceIfUnchanged.Hash(), []string{"order:2"}, string(outdatedData), string(repairedData), time.Hour.Milliseconds()).SetVal(int64(1))
+	publish("2")
+	mock.ExpectEvalSha(replaceIfUnchanged.Hash(), []string{"order:3"}, string(orphanData), "", int64(0)).SetVal(int64(1))
+	publish("3")
+	// Запись "5" успели перезаписать после чтения: она не трогается
+	mock.ExpectEvalSha(replaceIfUnchanged.Hash(), []string{"order:5"}, "{", "", int64(0)).SetVal(int64(0))
+
+	markers := []string{"notfound:4", "notfound:7"}
+	mock.ExpectScan(0, "notfound:*", 10).SetVal(markers, 0)
+	mock.ExpectMGet(markers...).SetVal([]any{notFoundMarker, notFoundMarker})
+
+	markerRows := sqlmock.NewRows(preloadOrderColumns)
+	preloadOrderRow(markerRows, "4", created, `[]`)
+	sqlMock.ExpectQuery(`FROM orders o`).
+		WithArgs([]string{"4", "7"}).
+		WillReturnRows(markerRows)
+
+	mock.ExpectEvalSha(replaceIfUnchanged.Hash(), []string{"notfound:4"}, notFoundMarker, "", int64(0)).SetVal(int64(1))
+	publish("4")
+
+	mock.ExpectEvalSha(releaseLock.Hash(), []string{reconcileLockKey}, c.instanceID).SetVal(int64(1))
+
+	report, err := c.Reconcile(ctx, domain.ReconcileOptions{BatchSize: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, report.Scanned)
+	assert.Equal(t, 2, report.Matched)
+	assert.Equal(t, 1, report.Repaired)
+	assert.Equal(t, 1, report.Orphans)
+	assert.Equal(t, 1, report.StaleMarkers)
+	assert.Equal(t, 1, report.Corrupted)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, []string{"2", "3", "5", "4"}, report.Mismatched)
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisCache_Reconcile_Uncacheable(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, sqlMock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Политику ужесточили: заказ больше не кешируется, и сверка удаляет
+	// его запись, даже если она совпадает с БД
+	c := NewRedisCacheRepository(rdb, db, WithPolicy(domain.Policy{TTL: time.Hour, MaxBytes: 10}))
+	ctx := context.Background()
+
+	created := time.Date(2025, 8, 26, 12, 0, 0, 0, time.UTC)
+	orderRows := preloadOrderRow(sqlmock.NewRows(preloadOrderColumns), "1", created, `[]`)
+
+	mock.ExpectSetNX(reconcileLockKey, c.instanceID, reconcileLockTTL).SetVal(true)
+	mock.ExpectScan(0, "order:*", 10).SetVal([]string{"order:1"}, 0)
+	mock.ExpectMGet("order:1").SetVal([]any{"cached"})
+	sqlMock.ExpectQuery(`FROM orders o`).WithArgs([]string{"1"}).WillReturnRows(orderRows)
+	mock.ExpectEvalSha(replaceIfUnchanged.Hash(), []string{"order:1"}, "cached", "", int64(0)).SetVal(int64(1))
+	mock.ExpectScan(0, "notfound:*", 10).SetVal(nil, 0)
+	mock.ExpectEvalSha(releaseLock.Hash(), []string{reconcileLockKey}, c.instanceID).SetVal(int64(1))
+
+	report, err := c.Reconcile(ctx, domain.ReconcileOptions{BatchSize: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Uncacheable)
+	assert.Equal(t, 1, report.Fixed())
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisCache_Reconcile_Locked(t *testing.T) {
+	rdb, mock := redismock.NewClientMock()
+	db, _, _ := sqlmock.New()
+	c := NewRedisCacheRepository(rdb, db)
+
+	mock.ExpectSetNX(reconcileLockKey, c.instanceID, reconcileLockTTL).SetVal(false)
+
+	report, err := c.Reconcile(context.Background(), domain.ReconcileOptions{DryRun: true})
+	assert.ErrorIs(t, err, ErrReconcileRunning)
+	assert.Nil(t, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
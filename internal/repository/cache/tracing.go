@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sayhellolexa/order-service/internal/tracing"
+)
+
+var tracer = otel.Tracer("github.com/sayhellolexa/order-service/internal/repository/cache")
+
+// startCommand начинает спан обращения к Redis за заказом orderUID.
+// Возвращённая функция завершает спан
+func startCommand(ctx context.Context, operation, orderUID string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "redis "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameRedis,
+			semconv.DBOperationName(operation),
+			attribute.String("order.uid", orderUID),
+		),
+	)
+
+	return ctx, func(err error) {
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// parsedOrder - заказ из пакета вместе с позицией и источником его сообщения
+type parsedOrder struct {
+	index  int
+	order  *model.Order
+	hash   string
+	source domain.Source
+}
+
+// SaveOrders сохраняет пакет заказов. Новые заказы записываются одной
+// транзакцией через COPY, уже известные - по одному с учётом политики
+// конфликтов. Возвращает ошибку для каждого сообщения: битый или
+// невалидный заказ не мешает сохранению остальных
+func (r *OrderRepository) SaveOrders(ctx context.Context, messages [][]byte) []error {
+	ctx, end := startQuery(ctx, "save_orders")
+
+	errs := make([]error, len(messages))
+	defer func() { end(errors.Join(errs...)) }()
+
+	var parsed []parsedOrder
+	for i, message := range messages {
+		orderMsg, hash, err := parseOrder(message)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		parsed = append(parsed, parsedOrder{index: i, order: orderMsg, hash: hash, source: domain.BatchSourceFrom(ctx, i)})
+	}
+
+	if len(parsed) == 0 {
+		return errs
+	}
+
+	uids := make([]string, len(parsed))
+	for i, p := range parsed {
+		uids[i] = p.order.OrderUID
+	}
+
+	stored, err := r.storedHashes(ctx, uids)
+	if err != nil {
+		for _, p := range parsed {
+			errs[p.index] = err
+		}
+		return errs
+	}
+
+	// Новые заказы пишутся пачкой. Уже сохранённые и повторы order_uid
+	// внутри пакета идут по одному, чтобы сработала политика конфликтов
+	var fresh, single []parsedOrder
+	seen := make(map[string]bool, len(parsed))
+	for _, p := range parsed {
+		uid := p.order.OrderUID
+		hash, exists := stored[uid]
+
+		switch {
+		case exists && hash == p.hash:
+		case exists || seen[uid]:
+			single = append(single, p)
+		default:
+			fresh = append(fresh, p)
+		}
+		seen[uid] = true
+	}
+
+	if len(fresh) > 0 {
+		if err := r.copyOrders(ctx, fresh); err != nil {
+			// Пакет откатился целиком: сохраняем его заказы по одному,
+			// чтобы ошибка досталась только виновному сообщению
+			r.logger.WarnContext(ctx, "Batch insert failed, falling back to single inserts", "orders", len(fresh), "error", err)
+			single = append(fresh, single...)
+		}
+	}
+
+	for _, p := range single {
+		errs[p.index] = r.saveOrder(ctx, p.order, p.hash, p.source)
+	}
+
+	return errs
+}
+
+// storedHashes возвращает хеши содержимого уже сохранённых заказов
+func (r *OrderRepository) storedHashes(ctx context.Context, uids []string) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT order_uid, payload_hash FROM orders WHERE order_uid = ANY($1)`, uids,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error with select stored orders: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]string)
+	for rows.Next() {
+		var uid, hash string
+		if err := rows.Scan(&uid, &hash); err != nil {
+			return nil, fmt.Errorf("error with scan stored orders: %w", err)
+		}
+		stored[uid] = hash
+	}
+
+	return stored, rows.Err()
+}
+
+// copyOrders записывает новые заказы в одной транзакции через COPY
+func (r *OrderRepository) copyOrders(ctx context.Context, orders []parsedOrder) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error with acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		tx, err := r.beginCopy(ctx, driverConn)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+				r.logger.ErrorContext(ctx, "Failed to rollback transaction", "error", rbErr)
+			}
+		}()
+
+		tables, err := copyTables(orders)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			_, err := tx.CopyFrom(ctx, pgx.Identifier{table.name}, table.columns, pgx.CopyFromRows(table.rows))
+			if err != nil {
+				return fmt.Errorf("error with copy into %s: %w", table.name, err)
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("error committing trans: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// beginPgxTx начинает транзакцию на нативном соединении pgx, которое
+// стоит за соединением database/sql: COPY доступен только через него
+func beginPgxTx(ctx context.Context, driverConn any) (pgx.Tx, error) {
+	stdConn, ok := driverConn.(*stdlib.Conn)
+	if !ok {
+		return nil, fmt.Errorf("error with copy: unexpected driver connection %T", driverConn)
+	}
+
+	tx, err := stdConn.Conn().Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	return tx, nil
+}
+
+type copyTable struct {
+	name    string
+	columns []string
+	rows    [][]any
+}
+
+// copyTables раскладывает заказы по строкам таблиц в порядке их зависимостей
+// и добавляет для каждого запись о создании в журнал изменений
+func copyTables(orders []parsedOrder) ([]copyTable, error) {
+	ordersTable := copyTable{name: "orders", columns: []string{
+		"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+		"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "version", "payload_hash",
+	}}
+	deliveriesTable := copyTable{name: "deliveries", columns: []string{
+		"order_uid", "name", "phone", "zip", "city", "address", "region", "email",
+	}}
+	paymentsTable := copyTable{name: "payments", columns: []string{
+		"order_uid", "transaction", "request_id", "currency", "provider", "amount",
+		"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+	}}
+	itemsTable := copyTable{name: "items", columns: []string{
+		"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size",
+		"total_price", "nm_id", "brand", "status",
+	}}
+	eventsTable := copyTable{name: "order_events", columns: []string{
+		"order_uid", "event_type", "source_type", "source_id", "new_value",
+	}}
+
+	for _, p := range orders {
+		o := p.order
+
+		ordersTable.rows = append(ordersTable.rows, []any{
+			o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+			o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, 1, p.hash,
+		})
+		deliveriesTable.rows = append(deliveriesTable.rows, []any{
+			o.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip,
+			o.Delivery.City, o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
+		})
+		paymentsTable.rows = append(paymentsTable.rows, []any{
+			o.OrderUID, o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency,
+			o.Payment.Provider, o.Payment.Amount, o.Payment.PaymentDt, o.Payment.Bank,
+			o.Payment.DeliveryCost, o.Payment.GoodsTotal, o.Payment.CustomFee,
+		})
+		for _, item := range o.Items {
+			itemsTable.rows = append(itemsTable.rows, []any{
+				o.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
+				item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+			})
+		}
+
+		newValue, err := eventValue(o)
+		if err != nil {
+			return nil, err
+		}
+		eventsTable.rows = append(eventsTable.rows, []any{
+			o.OrderUID, string(domain.EventCreated), p.source.Type, p.source.ID, newValue,
+		})
+	}
+
+	return []copyTable{ordersTable, deliveriesTable, paymentsTable, itemsTable, eventsTable}, nil
+}
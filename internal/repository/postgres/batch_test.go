@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+)
+
+// fakeCopyTx запоминает строки, записанные через COPY, и может
+// завершить COPY в таблицу failOn ошибкой err
+type fakeCopyTx struct {
+	pgx.Tx
+	failOn     string
+	err        error
+	copied     map[string][][]any
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeCopyTx) CopyFrom(_ context.Context, table pgx.Identifier, _ []string, src pgx.CopyFromSource) (int64, error) {
+	name := table[0]
+	if name == tx.failOn {
+		return 0, tx.err
+	}
+
+	var n int64
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return n, err
+		}
+		tx.copied[name] = append(tx.copied[name], values)
+		n++
+	}
+	return n, nil
+}
+
+func (tx *fakeCopyTx) Commit(context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeCopyTx) Rollback(context.Context) error {
+	if tx.committed {
+		return pgx.ErrTxClosed
+	}
+	tx.rolledBack = true
+	return nil
+}
+
+func TestOrderRepository_SaveOrders(t *testing.T) {
+	base := loadTestOrder(t)
+
+	message := func(uid string) []byte {
+		o := base
+		o.OrderUID = uid
+		data, err := json.Marshal(o)
+		require.NoError(t, err)
+		return data
+	}
+	hashOf := func(message []byte) string {
+		_, hash, err := parseOrder(message)
+		require.NoError(t, err)
+		return hash
+	}
+
+	storedQuery := `SELECT order_uid, payload_hash FROM orders WHERE order_uid = ANY\(\$1\)`
+	selectExisting := `SELECT payload_hash, version FROM orders WHERE order_uid = \$1 FOR UPDATE`
+
+	// expectInsert - сохранение нового заказа по одному, без COPY
+	expectInsert := func(mock sqlmock.Sqlmock, uid string) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectExisting).WithArgs(uid).WillReturnError(sql.ErrNoRows)
+		mock.ExpectExec(`INSERT INTO orders .+ ON CONFLICT \(order_uid\) DO UPDATE`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO deliveries`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO payments`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM items WHERE order_uid = \$1`).WithArgs(uid).WillReturnResult(sqlmock.NewResult(0, 0))
+		for range base.Items {
+			mock.ExpectExec(`INSERT INTO items`).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectExec(`INSERT INTO order_events`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	expectConflict := func(mock sqlmock.Sqlmock, uid string) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectExisting).WithArgs(uid).
+			WillReturnRows(sqlmock.NewRows([]string{"payload_hash", "version"}).AddRow("other", 1))
+		mock.ExpectRollback()
+	}
+
+	newDB := func(t *testing.T) (*OrderRepository, sqlmock.Sqlmock, *fakeCopyTx) {
+		db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		tx := &fakeCopyTx{copied: make(map[string][][]any)}
+		repo := NewOrderRepository(db)
+		repo.beginCopy = func(context.Context, any) (pgx.Tx, error) { return tx, nil }
+		return repo, mock, tx
+	}
+
+	sources := []domain.Source{
+		{Type: domain.SourceKafka, ID: "orders[0]@10"},
+		{Type: domain.SourceKafka, ID: "orders[0]@11"},
+		{Type: domain.SourceKafka, ID: "orders[0]@12"},
+		{Type: domain.SourceKafka, ID: "orders[0]@13"},
+	}
+	batchCtx := domain.WithBatchSources(ctx, sources)
+
+	t.Run("New orders copied in one transaction", func(t *testing.T) {
+		repo, mock, tx := newDB(t)
+		messages := [][]byte{message("new-1"), message("new-2")}
+
+		mock.ExpectQuery(storedQuery).WithArgs([]string{"new-1", "new-2"}).
+			WillReturnRows(sqlmock.NewRows([]string{"order_uid", "payload_hash"}))
+
+		errs := repo.SaveOrders(batchCtx, messages)
+
+		assert.Equal(t, []error{nil, nil}, errs)
+		assert.True(t, tx.committed)
+		assert.False(t, tx.rolledBack)
+
+		require.Len(t, tx.copied["orders"], 2)
+		assert.Equal(t, "new-1", tx.copied["orders"][0][0])
+		assert.Equal(t, hashOf(messages[1]), tx.copied["orders"][1][12])
+		assert.Len(t, tx.copied["deliveries"], 2)
+		assert.Len(t, tx.copied["payments"], 2)
+		assert.Len(t, tx.copied["items"], 2*len(base.Items))
+
+		// Запись о создании заказа хранит источник его сообщения
+		require.Len(t, tx.copied["order_events"], 2)
+		assert.Equal(t, []any{"new-2", string(domain.EventCreated), sources[1].Type, sources[1].ID},
+			tx.copied["order_events"][1][:4])
+
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Conflicting row falls back to single inserts", func(t *testing.T) {
+		repo, mock, tx := newDB(t)
+		tx.failOn = "orders"
+		tx.err = &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}
+
+		messages := [][]byte{message("new-1"), message("raced")}
+
+		mock.ExpectQuery(storedQuery).WithArgs([]string{"new-1", "raced"}).
+			WillReturnRows(sqlmock.NewRows([]string{"order_uid", "payload_hash"}))
+		// Заказ raced успели сохранить с другим содержимым между
+		// чтением хешей и COPY: ошибка достаётся только ему
+		expectInsert(mock, "new-1")
+		expectConflict(mock, "raced")
+
+		errs := repo.SaveOrders(batchCtx, messages)
+
+		require.Len(t, errs, 2)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], domain.ErrOrderConflict)
+		assert.True(t, tx.rolledBack)
+		assert.False(t, tx.committed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Mixed stored hashes", func(t *testing.T) {
+		repo, mock, tx := newDB(t)
+
+		messages := [][]byte{message("same"), message("changed"), []byte(`{"order_uid":`), message("new-1")}
+
+		mock.ExpectQuery(storedQuery).WithArgs([]string{"same", "changed", "new-1"}).
+			WillReturnRows(sqlmock.NewRows([]string{"order_uid", "payload_hash"}).
+				AddRow("same", hashOf(messages[0])).
+				AddRow("changed", "other"))
+		// Повтор без изменений не пишется, изменённый заказ проходит
+		// через политику конфликтов, новый - через COPY
+		expectConflict(mock, "changed")
+
+		errs := repo.SaveOrders(batchCtx, messages)
+
+		require.Len(t, errs, 4)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], domain.ErrOrderConflict)
+		assert.ErrorIs(t, errs[2], domain.ErrInvalidOrder)
+		assert.NoError(t, errs[3])
+
+		require.Len(t, tx.copied["orders"], 1)
+		assert.Equal(t, "new-1", tx.copied["orders"][0][0])
+		assert.True(t, tx.committed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,42 +1,82 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
+	"regexp"
 
-	"github.com/pressly/goose/v3"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
-func Connect(settings Settings) (*sql.DB, error) {
-	sqlInfo := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		settings.Host, settings.Port, settings.User, settings.Pass, settings.Name, settings.SslMode,
-	)
+// redactedPassword заменяет пароль в строке подключения в логах
+const redactedPassword = "xxxxx"
+
+// passwordParam находит пароль в строке подключения вида key=value
+var passwordParam = regexp.MustCompile(`(?i)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
 
-	db, err := sql.Open("pgx", sqlInfo)
+// NewPool открывает пул подключений к PostgreSQL. Схема не меняется:
+// миграции применяет Migrator
+func NewPool(ctx context.Context, settings Settings) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(settings.DSN)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to parse database settings: %w", err)
 	}
 
-	log.Printf("Database connection was created: %s \n", sqlInfo)
-
-	// Дропнуть структуру
-	if settings.Reload {
-		log.Print("Start reloading database \n")
-		err = goose.DownTo(db, "./migrations", 0)
-		if err != nil {
-			return db, fmt.Errorf("error down to: %w", err)
-		}
+	if settings.MaxConns > 0 {
+		config.MaxConns = int32(settings.MaxConns)
+	}
+	if settings.MinConns > 0 {
+		config.MinConns = int32(settings.MinConns)
+	}
+	if settings.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = settings.MaxConnLifetime
+	}
+	if settings.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = settings.MaxConnIdleTime
 	}
+	if settings.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = settings.HealthCheckPeriod
+	}
+	// Запрос готовится на подключении при первом выполнении и затем
+	// переиспользуется. Заранее готовить нельзя: до миграций таблиц нет
+	config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
 
-	// Создать заново
-	log.Print("Start migrating database \n")
-	err = goose.Up(db, "./migrations")
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	return db, nil
-}
\ No newline at end of file
+	slog.InfoContext(ctx, "Database pool was created",
+		"dsn", RedactDSN(settings.DSN),
+		"max_conns", config.MaxConns,
+		"min_conns", config.MinConns,
+		"max_conn_lifetime", config.MaxConnLifetime,
+		"health_check_period", config.HealthCheckPeriod,
+	)
+
+	return pool, nil
+}
+
+// OpenDB возвращает *sql.DB поверх пула: database/sql и pgx делят одни
+// подключения и одни ограничения пула. Закрывать его нужно до пула
+func OpenDB(pool *pgxpool.Pool) *sql.DB {
+	return stdlib.OpenDBFromPool(pool)
+}
+
+// RedactDSN скрывает пароль в строке подключения вида URL или key=value
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		// Пароль можно передать и параметром запроса
+		if query := u.Query(); query.Has("password") {
+			query.Set("password", redactedPassword)
+			u.RawQuery = query.Encode()
+		}
+		return u.Redacted()
+	}
+	return passwordParam.ReplaceAllString(dsn, "${1}"+redactedPassword)
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// recordEvent пишет изменение заказа в журнал в той же транзакции, что и само изменение.
+// Пустые старое или новое значение сохраняются как NULL
+func recordEvent(ctx context.Context, tx *sql.Tx, orderUID string, eventType domain.EventType, source domain.Source, oldValue, newValue any) error {
+	oldJSON, err := eventValue(oldValue)
+	if err != nil {
+		return err
+	}
+	newJSON, err := eventValue(newValue)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO order_events (order_uid, event_type, source_type, source_id, old_value, new_value)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		orderUID, eventType, source.Type, source.ID, oldJSON, newJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("error with order event insert query: %w", err)
+	}
+
+	return nil
+}
+
+// eventValue сериализует значение для журнала. nil-заказ даёт NULL
+func eventValue(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if order, ok := value.(*model.Order); ok && order == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling order event value: %w", err)
+	}
+	return string(data), nil
+}
+
+// statusValue - значение журнала для смены статуса
+type statusValue struct {
+	Status model.OrderStatus `json:"status"`
+}
+
+// GetOrderHistory возвращает журнал изменений заказа. Для заказа без записей
+// в журнале проверяется, что он существует, иначе возвращается domain.ErrOrderNotFound
+func (r *OrderRepository) GetOrderHistory(ctx context.Context, orderUID string) (_ []domain.Event, err error) {
+	ctx, end := startQuery(ctx, "get_order_history")
+	defer func() { end(err) }()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, order_uid, event_type, source_type, source_id, old_value, new_value, created_at
+		FROM order_events
+		WHERE order_uid = $1
+		ORDER BY id`, orderUID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error with select order events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.Event{}
+	for rows.Next() {
+		var event domain.Event
+		var oldValue, newValue []byte
+		err := rows.Scan(&event.ID, &event.OrderUID, &event.Type, &event.Source.Type, &event.Source.ID,
+			&oldValue, &newValue, &event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error with scan order event: %w", err)
+		}
+		event.OldValue = oldValue
+		event.NewValue = newValue
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error with read order events: %w", err)
+	}
+
+	if len(events) == 0 {
+		var exists bool
+		err := r.db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, orderUID,
+		).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("error with check order exists: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("error with history of order %s: %w", orderUID, domain.ErrOrderNotFound)
+		}
+	}
+
+	return events, nil
+}
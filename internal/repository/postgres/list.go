@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// ListOrders возвращает страницу заказов, отсортированных по убыванию
+// (date_created, order_uid). Следующая страница начинается после курсора
+func (r *OrderRepository) ListOrders(ctx context.Context, filter domain.ListFilter) (_ *domain.OrderPage, err error) {
+	ctx, end := startQuery(ctx, "list_orders")
+	defer func() { end(err) }()
+
+	var conds []string
+	var args []any
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.CustomerID != "" {
+		conds = append(conds, "o.customer_id = "+arg(filter.CustomerID))
+	}
+	if filter.TrackNumber != "" {
+		conds = append(conds, "o.track_number = "+arg(filter.TrackNumber))
+	}
+	if filter.DeliveryService != "" {
+		conds = append(conds, "o.delivery_service = "+arg(filter.DeliveryService))
+	}
+	if filter.Currency != "" {
+		conds = append(conds, "p.currency = "+arg(filter.Currency))
+	}
+	if filter.Brand != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.brand = "+arg(filter.Brand)+")")
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conds = append(conds, "o.date_created >= "+arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conds = append(conds, "o.date_created < "+arg(filter.CreatedTo))
+	}
+	if filter.After != nil {
+		conds = append(conds, fmt.Sprintf("(o.date_created, o.order_uid) < (%s, %s)",
+			arg(filter.After.DateCreated), arg(filter.After.OrderUID)))
+	}
+
+	query := orderSelect
+	if len(conds) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conds, " AND ")
+	}
+	// Лишняя запись показывает, есть ли следующая страница
+	query += "\n\t\tORDER BY o.date_created DESC, o.order_uid DESC LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error with list orders query: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*model.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error with scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &domain.OrderPage{Orders: orders}
+	if len(orders) > filter.Limit {
+		page.Orders = orders[:filter.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		page.Next = &domain.Cursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}
+	}
+
+	if err := r.attachItems(ctx, page.Orders); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// attachItems загружает товары сразу для всех заказов одним запросом
+func (r *OrderRepository) attachItems(ctx context.Context, orders []*model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byUID := make(map[string]*model.Order, len(orders))
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		byUID[order.OrderUID] = order
+		uids[i] = order.OrderUID
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT order_uid, `+itemColumns+` FROM items WHERE order_uid = ANY($1) ORDER BY id`, uids,
+	)
+	if err != nil {
+		return fmt.Errorf("error with items query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid string
+		item, err := scanItem(rows, &uid)
+		if err != nil {
+			return fmt.Errorf("error with scan item: %w", err)
+		}
+		if order, ok := byUID[uid]; ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+
+	return rows.Err()
+}
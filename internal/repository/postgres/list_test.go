@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+)
+
+func TestOrderRepository_ListOrdersFilters(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	testOrder := loadTestOrder(t)
+	from := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	// Записей ровно столько, сколько запрошено: следующей страницы нет
+	orderRows := sqlmock.NewRows([]string{
+		"order_uid", "track_number", "entry", "locale", "internal_signature",
+		"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status",
+		"name", "phone", "zip", "city", "address", "region", "email",
+		"transaction", "request_id", "currency", "provider", "amount",
+		"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+	}).AddRow(rowValues(orderValues(testOrder))...)
+
+	mock.ExpectQuery(
+		`SELECT (.+) FROM orders o JOIN deliveries d ON .+ JOIN payments p ON .+ `+
+			`WHERE o\.track_number = \$1 AND o\.delivery_service = \$2 AND p\.currency = \$3 `+
+			`AND EXISTS \(SELECT 1 FROM items i WHERE i\.order_uid = o\.order_uid AND i\.brand = \$4\) `+
+			`AND o\.date_created >= \$5 AND o\.date_created < \$6 `+
+			`ORDER BY o\.date_created DESC, o\.order_uid DESC LIMIT \$7`,
+	).WithArgs("WBILMTESTTRACK", "meest", "USD", "Adidas", from, to, 2).WillReturnRows(orderRows)
+
+	mock.ExpectQuery(`SELECT order_uid, (.+) FROM items WHERE order_uid = ANY\(\$1\) ORDER BY id`).
+		WithArgs([]string{testOrder.OrderUID}).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"order_uid", "chrt_id", "track_number", "price", "rid", "name",
+			"sale", "size", "total_price", "nm_id", "brand", "status",
+		}).AddRow(rowValues(append([]any{testOrder.OrderUID}, itemValues(testOrder.Items[0])...))...))
+
+	repo := NewOrderRepository(db)
+	page, err := repo.ListOrders(ctx, domain.ListFilter{
+		TrackNumber:     "WBILMTESTTRACK",
+		DeliveryService: "meest",
+		Currency:        "USD",
+		Brand:           "Adidas",
+		CreatedFrom:     from,
+		CreatedTo:       to,
+		Limit:           1,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 1)
+	assert.Equal(t, &testOrder, page.Orders[0])
+	assert.Nil(t, page.Next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// rowValues переводит колонки строки в значения для sqlmock
+func rowValues(values []any) []driver.Value {
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		row[i] = v
+	}
+	return row
+}
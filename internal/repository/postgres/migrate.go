@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// MigrationStatus - состояние одной миграции
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator применяет миграции схемы из fsys. Все изменения выполняются
+// под advisory lock, поэтому реплики, запущенные одновременно, применяют
+// миграции по очереди, а не наперегонки
+type Migrator struct {
+	provider *goose.Provider
+	// unlocked выполняет миграции без своей блокировки. Им пользуются
+	// операции из нескольких шагов, которые сами держат locker на всё время
+	unlocked *goose.Provider
+	locker   lock.SessionLocker
+	db       *sql.DB
+	logger   *slog.Logger
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration lock: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	unlocked, err := goose.NewProvider(goose.DialectPostgres, db, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	return &Migrator{provider: provider, unlocked: unlocked, locker: locker, db: db, logger: logger}, nil
+}
+
+// Up применяет все новые миграции
+func (m *Migrator) Up(ctx context.Context) error {
+	results, err := m.provider.Up(ctx)
+	m.report(ctx, results)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database up: %w", err)
+	}
+	if len(results) == 0 {
+		m.logger.InfoContext(ctx, "Database schema is up to date")
+	}
+	return nil
+}
+
+// Down откатывает последнюю применённую миграцию
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.down(ctx, m.provider)
+}
+
+func (m *Migrator) down(ctx context.Context, provider *goose.Provider) error {
+	result, err := provider.Down(ctx)
+	m.report(ctx, []*goose.MigrationResult{result})
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		m.logger.InfoContext(ctx, "No migrations to roll back")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate database down: %w", err)
+	}
+	return nil
+}
+
+// Redo откатывает и заново применяет последнюю миграцию. Оба шага
+// выполняются под одной блокировкой: иначе реплика с автоматическими
+// миграциями успела бы между ними применить откаченную миграцию сама
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		version, err := m.unlocked.GetDBVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get database version: %w", err)
+		}
+		if version == 0 {
+			return errors.New("failed to redo migration: no migrations applied")
+		}
+
+		if err := m.down(ctx, m.unlocked); err != nil {
+			return err
+		}
+
+		result, err := m.unlocked.ApplyVersion(ctx, version, true)
+		m.report(ctx, []*goose.MigrationResult{result})
+		if err != nil {
+			return fmt.Errorf("failed to reapply migration %d: %w", version, err)
+		}
+		return nil
+	})
+}
+
+// withLock выполняет fn, удерживая блокировку миграций на отдельном
+// соединении. Внутри fn миграции выполняются через unlocked
+func (m *Migrator) withLock(ctx context.Context, fn func() error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.locker.SessionLock(ctx, conn); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Блокировка снимается и при отменённом ctx, иначе она живёт до
+		// закрытия соединения пулом
+		if unlockErr := m.locker.SessionUnlock(context.WithoutCancel(ctx), conn); unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to release migration lock: %w", unlockErr))
+		}
+	}()
+
+	return fn()
+}
+
+// To приводит схему к версии version: применяет миграции до неё
+// или откатывает более новые. Версия 0 откатывает все миграции
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	var results []*goose.MigrationResult
+	if version >= current {
+		results, err = m.provider.UpTo(ctx, version)
+	} else {
+		results, err = m.provider.DownTo(ctx, version)
+	}
+	m.report(ctx, results)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database to version %d: %w", version, err)
+	}
+	return nil
+}
+
+// Version возвращает версию последней применённой миграции; 0 - ни одной
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	version, err := m.provider.GetDBVersion(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database version: %w", err)
+	}
+	return version, nil
+}
+
+// Status возвращает состояние всех миграций по возрастанию версий
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migrations status: %w", err)
+	}
+
+	result := make([]MigrationStatus, 0, len(statuses))
+	for _, s := range statuses {
+		result = append(result, MigrationStatus{
+			Version:   s.Source.Version,
+			Name:      path.Base(s.Source.Path),
+			Applied:   s.State == goose.StateApplied,
+			AppliedAt: s.AppliedAt,
+		})
+	}
+	return result, nil
+}
+
+// report логирует выполненные миграции
+func (m *Migrator) report(ctx context.Context, results []*goose.MigrationResult) {
+	for _, r := range results {
+		if r == nil || r.Source == nil {
+			continue
+		}
+		attrs := []any{"version", r.Source.Version, "name", path.Base(r.Source.Path), "direction", r.Direction, "duration", r.Duration}
+		if r.Error != nil {
+			m.logger.ErrorContext(ctx, "Migration failed", append(attrs, "error", r.Error)...)
+			continue
+		}
+		m.logger.InfoContext(ctx, "Migration applied", attrs...)
+	}
+}
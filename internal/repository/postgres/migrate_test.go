@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sayhellolexa/order-service/migrations"
+)
+
+func TestNewMigrator_EmbeddedMigrations(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := NewMigrator(db, migrations.FS, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	// Миграции встроены в бинарник и не зависят от рабочего каталога
+	sources := migrator.provider.ListSources()
+	require.NotEmpty(t, sources)
+	assert.Equal(t, int64(202508261301), sources[0].Version)
+}
+
+// recordingLocker запоминает, в каком порядке брались и снимались блокировки
+type recordingLocker struct {
+	calls []string
+}
+
+func (l *recordingLocker) SessionLock(context.Context, *sql.Conn) error {
+	l.calls = append(l.calls, "lock")
+	return nil
+}
+
+func (l *recordingLocker) SessionUnlock(context.Context, *sql.Conn) error {
+	l.calls = append(l.calls, "unlock")
+	return nil
+}
+
+func TestMigrator_RedoHoldsOneLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := NewMigrator(db, migrations.FS, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	locker := &recordingLocker{}
+	migrator.locker = locker
+
+	// Версия читается уже под блокировкой: ошибка чтения снимает её
+	mock.ExpectQuery(`goose_db_version`).WillReturnError(sql.ErrConnDone)
+
+	err = migrator.Redo(ctx)
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	assert.Equal(t, []string{"lock", "unlock"}, locker.calls)
+}
+
+func TestMigrator_WithLock(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	locker := &recordingLocker{}
+	migrator := &Migrator{locker: locker, db: db}
+
+	err = migrator.withLock(ctx, func() error {
+		locker.calls = append(locker.calls, "down", "up")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lock", "down", "up", "unlock"}, locker.calls)
+}
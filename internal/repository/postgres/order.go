@@ -2,55 +2,149 @@ package postgres
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
 	model "github.com/sayhellolexa/order-service/internal/model"
+	"github.com/sayhellolexa/order-service/internal/validation"
+)
+
+// ConflictPolicy определяет, что делать, если пришёл заказ с уже
+// сохранённым order_uid, но с другим содержимым
+type ConflictPolicy string
+
+const (
+	// ConflictReject - отклонить изменённый заказ с ошибкой domain.ErrOrderConflict
+	ConflictReject ConflictPolicy = "reject"
+	// ConflictOverwrite - перезаписать заказ новым содержимым
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictVersion - перезаписать заказ, сохранив прежнее содержимое в order_versions
+	ConflictVersion ConflictPolicy = "version"
 )
 
+// ParseConflictPolicy разбирает название политики конфликтов
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(s); policy {
+	case ConflictReject, ConflictOverwrite, ConflictVersion:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q", s)
+	}
+}
+
+// queryer - общие методы *sql.DB и *sql.Tx, нужные для чтения заказа
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
 type OrderRepository struct {
-	db *sql.DB
+	db             *sql.DB
+	conflictPolicy ConflictPolicy
+	logger         *slog.Logger
+	// beginCopy начинает транзакцию для COPY на соединении из db
+	beginCopy func(ctx context.Context, driverConn any) (pgx.Tx, error)
+}
+
+// Option настраивает необязательные параметры репозитория
+type Option func(*OrderRepository)
+
+// WithConflictPolicy задаёт политику для изменённых заказов с существующим order_uid
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(r *OrderRepository) {
+		r.conflictPolicy = policy
+	}
+}
+
+// WithLogger задаёт логгер репозитория
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *OrderRepository) {
+		r.logger = logger
+	}
 }
 
 // Конструктор для нового экземпляра OrderRepository
-func NewOrderRepository(db *sql.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+func NewOrderRepository(db *sql.DB, opts ...Option) *OrderRepository {
+	r := &OrderRepository{db: db, conflictPolicy: ConflictReject, logger: slog.Default(), beginCopy: beginPgxTx}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *OrderRepository) GetOrderById(ctx context.Context, id string) (*model.Order, error) {
-	orderQuery := `
+	ctx, end := startQuery(ctx, "get_order")
+
+	order, err := getOrder(ctx, r.db, id)
+	end(err)
+	return order, err
+}
+
+// orderSelect выбирает заказ вместе с доставкой и оплатой, порядок
+// колонок соответствует scanOrder
+const orderSelect = `
         SELECT o.order_uid, o.track_number, o.entry, o.locale, 
 	         o.internal_signature, 
-             o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+             o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status,
              d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
              p.transaction, p.request_id, p.currency, p.provider, p.amount, 
              p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee 
        	FROM orders o
 		JOIN deliveries d ON o.order_uid = d.order_uid
-		JOIN payments p ON o.order_uid = p.order_uid
-		WHERE o.order_uid = $1
-	`
-	
-	itemsQuery := `
-	    SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		FROM items WHERE order_uid = $1
-	`
+		JOIN payments p ON o.order_uid = p.order_uid`
+
+// itemColumns - колонки товара в порядке scanItem
+const itemColumns = `chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanOrder(row rowScanner) (*model.Order, error) {
 	var order model.Order
 	var delivery model.Delivery
 	var payment model.Payment
 
-	var items []model.Item
-
-	err := r.db.QueryRowContext(ctx, orderQuery, id).Scan(
+	dest := []any{
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-        &order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard,
+        &order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Status,
         &delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email,
         &payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount,
         &payment.PaymentDt, &payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
-	)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	order.Delivery = delivery
+	order.Payment = payment
+
+	return &order, nil
+}
+
+// scanItem читает товар; prefix - колонки, выбранные перед колонками товара
+func scanItem(row rowScanner, prefix ...any) (model.Item, error) {
+	var item model.Item
+	err := row.Scan(append(prefix, &item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name,
+        &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status,
+	)...)
+	return item, err
+}
+
+func getOrder(ctx context.Context, q queryer, id string) (*model.Order, error) {
+	var items []model.Item
+
+	order, err := scanOrder(q.QueryRowContext(ctx, getOrderQuery, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -58,20 +152,14 @@ func (r *OrderRepository) GetOrderById(ctx context.Context, id string) (*model.O
 		return nil, err
 	}
 
-	order.Delivery = delivery
-	order.Payment = payment
-
-	rows, err := r.db.QueryContext(ctx, itemsQuery, id)
+	rows, err := q.QueryContext(ctx, getOrderItemsQuery, id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var item model.Item
-		err := rows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name,
-            &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status,
-		)
+		item, err := scanItem(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -83,42 +171,169 @@ func (r *OrderRepository) GetOrderById(ctx context.Context, id string) (*model.O
 
 	order.Items = items
 
-	return &order, nil
+	return order, nil
+}
+
+// SaveOrder идемпотентно сохраняет заказ из сообщения Kafka. Повторная доставка
+// того же заказа ничего не меняет, а изменённый заказ с существующим order_uid
+// обрабатывается согласно политике конфликтов
+func (r *OrderRepository) SaveOrder(ctx context.Context, message []byte) (err error) {
+	ctx, end := startQuery(ctx, "save_order")
+	defer func() { end(err) }()
+
+	orderMsg, hash, err := parseOrder(message)
+	if err != nil {
+		return err
+	}
+
+	return r.saveOrder(ctx, orderMsg, hash, domain.SourceFrom(ctx))
 }
 
-// TODO: Добавить валидацию данных перед сохранением в БД
-func (r *OrderRepository) SaveOrder(ctx context.Context, message []byte) error {
+// parseOrder разбирает и валидирует сообщение с заказом
+func parseOrder(message []byte) (*model.Order, string, error) {
 	var orderMsg model.Order
 	if err := json.Unmarshal(message, &orderMsg); err != nil {
-		return fmt.Errorf("error unmarshaling message: %w", err)
+		return nil, "", fmt.Errorf("error unmarshaling message: %w: %w", domain.ErrInvalidOrder, err)
 	}
 
-	err := validateOrder(&orderMsg)
+	err := validation.ValidateOrder(&orderMsg)
+	if err != nil {
+		return nil, "", fmt.Errorf("error validating order: %w: %w", domain.ErrInvalidOrder, err)
+	}
+
+	// Статус меняется только через UpdateStatus, а не сообщением с заказом
+	orderMsg.Status = ""
+
+	hash, err := hashOrder(&orderMsg)
 	if err != nil {
-		return fmt.Errorf("error validating order: %w", err)
+		return nil, "", err
 	}
 
-	tx, err := r.db.Begin()
+	return &orderMsg, hash, nil
+}
+
+// saveOrder сохраняет заказ и записывает изменение в журнал с источником source
+func (r *OrderRepository) saveOrder(ctx context.Context, orderMsg *model.Order, hash string, source domain.Source) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer func() {
-		if err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("failed to rollback transaction: %v", rbErr)
-			}
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.ErrorContext(ctx, "Failed to rollback transaction", "error", rbErr)
 		}
 	}()
 
+	var storedHash string
+	var version int
+	var previous *model.Order
+	eventType := domain.EventCreated
+	err = tx.QueryRowContext(ctx,
+		`SELECT payload_hash, version FROM orders WHERE order_uid = $1 FOR UPDATE`, orderMsg.OrderUID,
+	).Scan(&storedHash, &version)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		version = 1
+	case err != nil:
+		return fmt.Errorf("error with select existing order: %w", err)
+	default:
+		// Заказы, сохранённые до появления payload_hash, сравниваются по содержимому
+		if storedHash == "" {
+			if previous, err = getOrder(ctx, tx, orderMsg.OrderUID); err != nil {
+				return fmt.Errorf("error with load existing order: %w", err)
+			}
+			same, err := sameStoredContent(previous, orderMsg)
+			if err != nil {
+				return err
+			}
+			if same {
+				return nil
+			}
+		} else if storedHash == hash {
+			return nil
+		}
+
+		if r.conflictPolicy != ConflictOverwrite && r.conflictPolicy != ConflictVersion {
+			return fmt.Errorf("error saving order %s: %w", orderMsg.OrderUID, domain.ErrOrderConflict)
+		}
+
+		// Прежнее содержимое нужно журналу изменений, а для ConflictVersion - ещё и архиву
+		if previous == nil {
+			if previous, err = getOrder(ctx, tx, orderMsg.OrderUID); err != nil {
+				return fmt.Errorf("error with load existing order: %w", err)
+			}
+		}
+
+		if r.conflictPolicy == ConflictVersion {
+			// Заказ без доставки или оплаты целиком не собрать, архивировать нечего
+			if previous != nil {
+				if err := archiveVersion(ctx, tx, previous, version); err != nil {
+					return err
+				}
+			}
+		}
+
+		eventType = domain.EventUpdated
+		version++
+	}
+
+	if err := writeOrder(ctx, tx, orderMsg, hash, version); err != nil {
+		return constraintError(err)
+	}
+
+	if err := recordEvent(ctx, tx, orderMsg.OrderUID, eventType, source, previous, orderMsg); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing trans: %w", err)
+	}
+
+	return nil
+}
+
+// Нарушения ограничений схемы, при которых повторное сохранение заказа
+// снова завершится ошибкой
+const (
+	checkViolation = "23514"
+	// Уникальность transaction - свойство заказа. Другие уникальные ключи
+	// могут нарушаться гонкой параллельных записей, и такую ошибку стоит повторить
+	uniqueViolation       = "23505"
+	transactionConstraint = "payments_transaction_key"
+)
+
+// constraintError помечает нарушение ограничений схемы как domain.ErrInvalidOrder
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	if pgErr.Code == checkViolation || (pgErr.Code == uniqueViolation && pgErr.ConstraintName == transactionConstraint) {
+		return fmt.Errorf("%w: violates %s: %w", domain.ErrInvalidOrder, pgErr.ConstraintName, err)
+	}
+	return err
+}
+
+// writeOrder вставляет или перезаписывает заказ со всеми связанными записями.
+// Товары заменяются целиком, чтобы повторная запись не плодила дубликаты
+func writeOrder(ctx context.Context, tx *sql.Tx, orderMsg *model.Order, hash string, version int) error {
 	orderInsertQuery := `INSERT INTO orders (
 		order_uid, track_number, entry, locale, internal_signature, customer_id, 
-		delivery_service, shardkey, sm_id, date_created, oof_shard
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+		delivery_service, shardkey, sm_id, date_created, oof_shard, version, payload_hash
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT (order_uid) DO UPDATE SET
+		track_number = EXCLUDED.track_number, entry = EXCLUDED.entry, locale = EXCLUDED.locale,
+		internal_signature = EXCLUDED.internal_signature, customer_id = EXCLUDED.customer_id,
+		delivery_service = EXCLUDED.delivery_service, shardkey = EXCLUDED.shardkey,
+		sm_id = EXCLUDED.sm_id, date_created = EXCLUDED.date_created, oof_shard = EXCLUDED.oof_shard,
+		version = EXCLUDED.version, payload_hash = EXCLUDED.payload_hash`
 
-	_, err = tx.Exec(orderInsertQuery, 
+	_, err := tx.ExecContext(ctx, orderInsertQuery, 
 		orderMsg.OrderUID, orderMsg.TrackNumber, orderMsg.Entry, orderMsg.Locale,
 		orderMsg.InternalSignature, orderMsg.CustomerID, orderMsg.DeliveryService,
 		orderMsg.ShardKey, orderMsg.SmID, orderMsg.DateCreated, orderMsg.OofShard,
+		version, hash,
 	)
 	if err != nil {
 		return fmt.Errorf("error with order insert query: %w", err)
@@ -126,7 +341,10 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, message []byte) error {
 
 	deliveryInsertQuery := `INSERT INTO deliveries (
 		order_uid, name, phone, zip, city, address, region, email
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (order_uid) DO UPDATE SET
+		name = EXCLUDED.name, phone = EXCLUDED.phone, zip = EXCLUDED.zip, city = EXCLUDED.city,
+		address = EXCLUDED.address, region = EXCLUDED.region, email = EXCLUDED.email`
 	
 	_, err = tx.ExecContext(ctx, deliveryInsertQuery,
 		orderMsg.OrderUID, orderMsg.Delivery.Name, orderMsg.Delivery.Phone,
@@ -140,7 +358,12 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, message []byte) error {
 	paymentInsertQuery := `INSERT INTO payments (
 		order_uid, transaction, request_id, currency, provider, amount,
 		payment_dt, bank, delivery_cost, goods_total, custom_fee
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (order_uid) DO UPDATE SET
+		transaction = EXCLUDED.transaction, request_id = EXCLUDED.request_id,
+		currency = EXCLUDED.currency, provider = EXCLUDED.provider, amount = EXCLUDED.amount,
+		payment_dt = EXCLUDED.payment_dt, bank = EXCLUDED.bank, delivery_cost = EXCLUDED.delivery_cost,
+		goods_total = EXCLUDED.goods_total, custom_fee = EXCLUDED.custom_fee`
 
 	_, err = tx.ExecContext(ctx, paymentInsertQuery,
 		orderMsg.OrderUID, orderMsg.Payment.Transaction, orderMsg.Payment.RequestID,
@@ -152,6 +375,11 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, message []byte) error {
 		return fmt.Errorf("error with payment insert query: %w", err)
 	}
 
+	_, err = tx.ExecContext(ctx, `DELETE FROM items WHERE order_uid = $1`, orderMsg.OrderUID)
+	if err != nil {
+		return fmt.Errorf("error with item delete query: %w", err)
+	}
+
 	itemInserQuery := `INSERT INTO items (
 		order_uid, chrt_id, track_number, price, rid, name, sale, size,
 		total_price, nm_id, brand, status
@@ -163,14 +391,79 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, message []byte) error {
 			item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice,
 			item.NmID, item.Brand, item.Status,
 		)
+		if err != nil {
+			return fmt.Errorf("error with item insert query: %w", err)
+		}
 	}
+
+	return nil
+}
+
+// archiveVersion сохраняет текущее содержимое заказа перед перезаписью
+func archiveVersion(ctx context.Context, tx *sql.Tx, previous *model.Order, version int) error {
+	payload, err := json.Marshal(previous)
 	if err != nil {
-		return fmt.Errorf("error with item insert query: %w", err)
+		return fmt.Errorf("error marshaling previous order version: %w", err)
 	}
-	
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing trans: %w", err)
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO order_versions (order_uid, version, payload) VALUES ($1, $2, $3)`,
+		previous.OrderUID, version, payload,
+	)
+	if err != nil {
+		return fmt.Errorf("error with order version insert query: %w", err)
 	}
 
 	return nil
 }
+
+// sameStoredContent сравнивает заказ, прочитанный из БД, с заказом из
+// сообщения. Заказ из сообщения приводится к виду, в котором его вернула
+// бы БД, иначе повторная доставка того же сообщения выглядела бы изменением
+func sameStoredContent(stored, order *model.Order) (bool, error) {
+	if stored == nil {
+		return false, nil
+	}
+
+	storedHash, err := hashOrder(storedForm(stored))
+	if err != nil {
+		return false, err
+	}
+	hash, err := hashOrder(storedForm(order))
+	if err != nil {
+		return false, err
+	}
+	return storedHash == hash, nil
+}
+
+// storedForm возвращает копию заказа в том виде, в котором он читается из
+// БД: колонка TIMESTAMP хранит время без часового пояса (pgx отбрасывает
+// пояс, сохраняя время на часах) с точностью до микросекунд, а заказ без
+// товаров читается с nil вместо пустого списка
+func storedForm(order *model.Order) *model.Order {
+	content := *order
+
+	t := order.DateCreated
+	content.DateCreated = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Truncate(time.Microsecond)
+
+	if len(content.Items) == 0 {
+		content.Items = nil
+	}
+	return &content
+}
+
+// hashOrder считает хеш содержимого заказа, по которому распознаются повторные доставки.
+// Статус не входит в содержимое: он меняется отдельно от заказа
+func hashOrder(order *model.Order) (string, error) {
+	content := *order
+	content.Status = ""
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling order for hash: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -1,17 +1,22 @@
 package postgres
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
 	model "github.com/sayhellolexa/order-service/internal/model"
 )
 
@@ -68,7 +73,7 @@ func TestOrderRepository_GetOrderById(t *testing.T) {
 				// Мокируем основной запрос
 				mainRows := sqlmock.NewRows([]string{
 					"order_uid", "track_number", "entry", "locale", "internal_signature",
-					"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+					"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status",
 					"name", "phone", "zip", "city", "address", "region", "email",
 					"transaction", "request_id", "currency", "provider", "amount",
 					"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
@@ -76,7 +81,7 @@ func TestOrderRepository_GetOrderById(t *testing.T) {
 					AddRow(
 						testOrder.OrderUID, testOrder.TrackNumber, testOrder.Entry, testOrder.Locale,
 						testOrder.InternalSignature, testOrder.CustomerID, testOrder.DeliveryService,
-						testOrder.ShardKey, testOrder.SmID, testOrder.DateCreated, testOrder.OofShard,
+						testOrder.ShardKey, testOrder.SmID, testOrder.DateCreated, testOrder.OofShard, testOrder.Status,
 						testOrder.Delivery.Name, testOrder.Delivery.Phone, testOrder.Delivery.Zip,
 						testOrder.Delivery.City, testOrder.Delivery.Address, testOrder.Delivery.Region,
 						testOrder.Delivery.Email, testOrder.Payment.Transaction, testOrder.Payment.RequestID,
@@ -101,7 +106,7 @@ func TestOrderRepository_GetOrderById(t *testing.T) {
 				}
 
 				mock.ExpectQuery(
-					`SELECT (.+) FROM items WHERE order_uid = \$1`,
+					`SELECT (.+) FROM items WHERE order_uid = \$1 ORDER BY id`,
 				).WithArgs(id).WillReturnRows(itemRows)
 			},
 			expected: &testOrder,
@@ -145,53 +150,331 @@ func TestOrderRepository_GetOrderById(t *testing.T) {
 	}
 }
 
-func TestValidateOrder(t *testing.T) {
-	validOrder := &model.Order{
-		OrderUID:   "123",
-		CustomerID: "cust1",
-		TrackNumber: "TRACK-123",
-		Delivery: model.Delivery{
-			Name:    "John",
-			Phone:   "12345",
-			Address: "Street",
-			City:    "City",
+func TestOrderRepository_SaveOrder(t *testing.T) {
+	message, err := os.ReadFile("testdata.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var testOrder model.Order
+	if err := json.Unmarshal(message, &testOrder); err != nil {
+		log.Fatal(err)
+	}
+
+	hash, err := hashOrder(&testOrder)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	selectExisting := `SELECT payload_hash, version FROM orders WHERE order_uid = \$1 FOR UPDATE`
+
+	source := domain.Source{Type: domain.SourceKafka, ID: "orders[0]@42"}
+	sourceCtx := domain.WithSource(ctx, source)
+
+	expectEvent := func(mock sqlmock.Sqlmock, eventType domain.EventType, oldValue any) {
+		mock.ExpectExec(`INSERT INTO order_events`).
+			WithArgs(testOrder.OrderUID, eventType, source.Type, source.ID, oldValue, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	expectWrite := func(mock sqlmock.Sqlmock, version int) {
+		mock.ExpectExec(`INSERT INTO orders .+ ON CONFLICT \(order_uid\) DO UPDATE`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), version, hash).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO deliveries`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO payments`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM items WHERE order_uid = \$1`).
+			WithArgs(testOrder.OrderUID).WillReturnResult(sqlmock.NewResult(0, 0))
+		for range testOrder.Items {
+			mock.ExpectExec(`INSERT INTO items`).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+	}
+
+	// Строка, сохранённая до появления payload_hash: дата без часового
+	// пояса, суммы - NUMERIC с двумя знаками после запятой
+	legacyMessage := bytes.Replace(message, []byte(`"2021-11-26T06:22:19Z"`), []byte(`"2021-11-26T09:22:19+03:00"`), 1)
+	expectLegacyRead := func(mock sqlmock.Sqlmock, trackNumber string) {
+		p := testOrder.Payment
+		mock.ExpectQuery(`FROM orders o`).WithArgs(testOrder.OrderUID).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"order_uid", "track_number", "entry", "locale", "internal_signature",
+				"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status",
+				"name", "phone", "zip", "city", "address", "region", "email",
+				"transaction", "request_id", "currency", "provider", "amount",
+				"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+			}).AddRow(
+				testOrder.OrderUID, trackNumber, testOrder.Entry, testOrder.Locale, testOrder.InternalSignature,
+				testOrder.CustomerID, testOrder.DeliveryService, testOrder.ShardKey, testOrder.SmID,
+				time.Date(2021, 11, 26, 9, 22, 19, 0, time.UTC), testOrder.OofShard, "paid",
+				testOrder.Delivery.Name, testOrder.Delivery.Phone, testOrder.Delivery.Zip, testOrder.Delivery.City,
+				testOrder.Delivery.Address, testOrder.Delivery.Region, testOrder.Delivery.Email,
+				p.Transaction, p.RequestID, p.Currency, p.Provider, "1817.00",
+				p.PaymentDt, p.Bank, "1500.00", "317.00", "0.00",
+			))
+
+		itemRows := sqlmock.NewRows([]string{
+			"chrt_id", "track_number", "price", "rid", "name",
+			"sale", "size", "total_price", "nm_id", "brand", "status",
+		})
+		for _, item := range testOrder.Items {
+			itemRows.AddRow(item.ChrtID, item.TrackNumber, "453.00", item.Rid, item.Name,
+				item.Sale, item.Size, "317.00", item.NmID, item.Brand, item.Status)
+		}
+		mock.ExpectQuery(`FROM items WHERE order_uid = \$1`).WithArgs(testOrder.OrderUID).WillReturnRows(itemRows)
+	}
+
+	testTable := []struct {
+		name    string
+		policy  ConflictPolicy
+		message []byte
+		mockDB  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name:   "New order",
+			policy: ConflictReject,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectExisting).WithArgs(testOrder.OrderUID).WillReturnError(sql.ErrNoRows)
+				expectWrite(mock, 1)
+				expectEvent(mock, domain.EventCreated, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:   "Identical redelivery",
+			policy: ConflictReject,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectExisting).WithArgs(testOrder.OrderUID).
+					WillReturnRows(sqlmock.NewRows([]string{"payload_hash", "version"}).AddRow(hash, 1))
+				mock.ExpectRollback()
+			},
 		},
-		Payment: model.Payment{
-			Transaction: "tx123",
-			Currency:    "USD",
-			Provider:    "bank",
-			Amount:      100,
+		{
+			name:   "Changed order rejected",
+			policy: ConflictReject,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectExisting).WithArgs(testOrder.OrderUID).
+					WillReturnRows(sqlmock.NewRows([]string{"payload_hash", "version"}).AddRow("other", 1))
+				mock.ExpectRollback()
+			},
+			wantErr: domain.ErrOrderConflict,
 		},
-		Items: []model.Item{
-			{ChrtID: 1, Name: "item"},
+		{
+			name:    "Identical redelivery of legacy order",
+			policy:  ConflictReject,
+			message: legacyMessage,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectExisting).WithArgs(testOrder.OrderUID).
+					WillReturnRows(sqlmock.NewRows([]string{"payload_hash", "version"}).AddRow("", 1))
+				expectLegacyRead(mock, testOrder.TrackNumber)
+				mock.ExpectRollback()
+			},
 		},
+		{
+			name:    "Changed legacy order rejected",
+			policy:  ConflictReject,
+			message: legacyMessage,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectExisting).WithArgs(testOrder.OrderUID).
+					WillReturnRows(sqlmock.NewRows([]string{"payload_hash", "version"}).AddRow("", 1))
+				expectLegacyRead(mock, "OTHERTRACK")
+				mock.ExpectRollback()
+			},
+			wantErr: domain.ErrOrderConflict,
+		},
+		{
+			name:   "Changed order overwritten",
+			policy: ConflictOverwrite,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectExisting).WithArgs(testOrder.OrderUID).
+					WillReturnRows(sqlmock.NewRows([]string{"payload_hash", "version"}).AddRow("other", 3))
+				mock.ExpectQuery(`FROM orders o`).WithArgs(testOrder.OrderUID).WillReturnError(sql.ErrNoRows)
+				expectWrite(mock, 4)
+				expectEvent(mock, domain.EventUpdated, nil)
+				mock.ExpectCommit()
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error creating sqlmock: %s", err)
+			}
+			defer db.Close()
+
+			testCase.mockDB(mock)
+
+			msg := message
+			if testCase.message != nil {
+				msg = testCase.message
+			}
+
+			repo := NewOrderRepository(db, WithConflictPolicy(testCase.policy))
+			err = repo.SaveOrder(sourceCtx, msg)
+			if testCase.wantErr != nil {
+				assert.ErrorIs(t, err, testCase.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// anyValueConverter пропускает срезы в аргументы запроса, как это делает драйвер pgx
+type anyValueConverter struct{}
+
+func (anyValueConverter) ConvertValue(v any) (driver.Value, error) {
+	return v, nil
+}
+
+func TestOrderRepository_ListOrders(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(anyValueConverter{}))
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %s", err)
 	}
+	defer db.Close()
 
-	invalidOrder := &model.Order{
-		OrderUID:   "",               
-		CustomerID: "",               
-		Delivery:   model.Delivery{}, 
-		Payment:    model.Payment{},  
-		Items:      []model.Item{},   
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	orderRows := sqlmock.NewRows([]string{
+		"order_uid", "track_number", "entry", "locale", "internal_signature",
+		"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status",
+		"name", "phone", "zip", "city", "address", "region", "email",
+		"transaction", "request_id", "currency", "provider", "amount",
+		"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+	})
+	for _, uid := range []string{"c", "b", "a"} {
+		orderRows.AddRow(
+			uid, "TRACK", "WBIL", "ru", "", "cust1", "meest", "9", 99, created, "1", "created",
+			"Test Testov", "+9720012345", "2639809", "City", "Street", "Region", "test@gmail.com",
+			"tx-"+uid, "", "USD", "wbpay", 1817, 1637907727, "alpha", 1500, 317, 0,
+		)
 	}
 
-	tests := []struct {
-		name    string
-		order   *model.Order
-		wantErr bool
+	cursor := &domain.Cursor{DateCreated: created, OrderUID: "d"}
+
+	mock.ExpectQuery(
+		`SELECT (.+) FROM orders o JOIN deliveries d ON .+ JOIN payments p ON .+ ` +
+			`WHERE o\.customer_id = \$1 AND \(o\.date_created, o\.order_uid\) < \(\$2, \$3\) ` +
+			`ORDER BY o\.date_created DESC, o\.order_uid DESC LIMIT \$4`,
+	).WithArgs("cust1", cursor.DateCreated, cursor.OrderUID, 3).WillReturnRows(orderRows)
+
+	mock.ExpectQuery(`SELECT order_uid, (.+) FROM items WHERE order_uid = ANY\(\$1\)`).
+		WithArgs([]string{"c", "b"}).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"order_uid", "chrt_id", "track_number", "price", "rid", "name",
+			"sale", "size", "total_price", "nm_id", "brand", "status",
+		}).AddRow("b", 1, "TRACK", 453, "rid", "Mascaras", 30, "0", 317, 2, "Adidas", 202))
+
+	repo := NewOrderRepository(db)
+	page, err := repo.ListOrders(ctx, domain.ListFilter{CustomerID: "cust1", After: cursor, Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Orders, 2)
+	assert.Empty(t, page.Orders[0].Items)
+	assert.Len(t, page.Orders[1].Items, 1)
+	assert.Equal(t, &domain.Cursor{DateCreated: created, OrderUID: "b"}, page.Next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderRepository_GetOrderHistory(t *testing.T) {
+	orderUID := "b563feb7b2b84b556test"
+	createdAt := time.Date(2025, 8, 26, 13, 0, 0, 0, time.UTC)
+
+	eventColumns := []string{
+		"id", "order_uid", "event_type", "source_type", "source_id", "old_value", "new_value", "created_at",
+	}
+
+	testTable := []struct {
+		name       string
+		mockDB     func(mock sqlmock.Sqlmock)
+		wantEvents []domain.Event
+		wantErr    error
 	}{
-		{"valid order", validOrder, false},
-		{"invalid order", invalidOrder, true},
+		{
+			name: "History",
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM order_events`).WithArgs(orderUID).
+					WillReturnRows(sqlmock.NewRows(eventColumns).
+						AddRow(1, orderUID, "created", "kafka", "orders[0]@42", nil, []byte(`{"order_uid":"b563feb7b2b84b556test"}`), createdAt).
+						AddRow(2, orderUID, "status_changed", "http", "req-1", []byte(`{"status":"created"}`), []byte(`{"status":"paid"}`), createdAt))
+			},
+			wantEvents: []domain.Event{
+				{
+					ID: 1, OrderUID: orderUID, Type: domain.EventCreated,
+					Source:    domain.Source{Type: domain.SourceKafka, ID: "orders[0]@42"},
+					NewValue:  json.RawMessage(`{"order_uid":"b563feb7b2b84b556test"}`),
+					CreatedAt: createdAt,
+				},
+				{
+					ID: 2, OrderUID: orderUID, Type: domain.EventStatusChanged,
+					Source:    domain.Source{Type: domain.SourceHTTP, ID: "req-1"},
+					OldValue:  json.RawMessage(`{"status":"created"}`),
+					NewValue:  json.RawMessage(`{"status":"paid"}`),
+					CreatedAt: createdAt,
+				},
+			},
+		},
+		{
+			name: "Order without events",
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM order_events`).WithArgs(orderUID).WillReturnRows(sqlmock.NewRows(eventColumns))
+				mock.ExpectQuery(`SELECT EXISTS`).WithArgs(orderUID).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			wantEvents: []domain.Event{},
+		},
+		{
+			name: "Order not found",
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM order_events`).WithArgs(orderUID).WillReturnRows(sqlmock.NewRows(eventColumns))
+				mock.ExpectQuery(`SELECT EXISTS`).WithArgs(orderUID).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			wantErr: domain.ErrOrderNotFound,
+		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateOrder(tt.order)
-			if tt.wantErr {
-				assert.Error(t, err)
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("error creating sqlmock: %s", err)
+			}
+			defer db.Close()
+
+			testCase.mockDB(mock)
+
+			events, err := NewOrderRepository(db).GetOrderHistory(ctx, orderUID)
+			if testCase.wantErr != nil {
+				assert.ErrorIs(t, err, testCase.wantErr)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, testCase.wantEvents, events)
 			}
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestConstraintError(t *testing.T) {
+	check := &pgconn.PgError{Code: "23514", ConstraintName: "items_sale_check"}
+	assert.ErrorIs(t, constraintError(fmt.Errorf("insert: %w", check)), domain.ErrInvalidOrder)
+
+	transaction := &pgconn.PgError{Code: "23505", ConstraintName: "payments_transaction_key"}
+	assert.ErrorIs(t, constraintError(transaction), domain.ErrInvalidOrder)
+
+	// Гонка за первичный ключ - временная ошибка
+	race := &pgconn.PgError{Code: "23505", ConstraintName: "order_versions_pkey"}
+	assert.NotErrorIs(t, constraintError(race), domain.ErrInvalidOrder)
+}
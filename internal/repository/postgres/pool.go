@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/sayhellolexa/order-service/internal/metrics"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// Запросы чтения заказа. Пул NewPool готовит их на подключении при первом
+// выполнении, дальше выполняется уже подготовленный запрос. Ими же читает
+// заказ OrderRepository, чтобы товары в обоих репозиториях шли в одном порядке
+const (
+	getOrderQuery      = orderSelect + ` WHERE o.order_uid = $1`
+	getOrderItemsQuery = `SELECT ` + itemColumns + ` FROM items WHERE order_uid = $1 ORDER BY id`
+)
+
+// PoolOrderRepository - OrderRepository поверх pgxpool. Горячее чтение
+// заказа идёт напрямую через pgx подготовленными запросами, остальные
+// методы - через database/sql поверх того же пула
+type PoolOrderRepository struct {
+	*OrderRepository
+	pool batchSender
+}
+
+// batchSender - часть pgxpool.Pool, через которую читается заказ
+type batchSender interface {
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// NewPoolOrderRepository создаёт репозиторий на пуле, открытом NewPool,
+// и db, открытой над ним через OpenDB
+func NewPoolOrderRepository(pool *pgxpool.Pool, db *sql.DB, opts ...Option) *PoolOrderRepository {
+	return &PoolOrderRepository{
+		OrderRepository: NewOrderRepository(db, opts...),
+		pool:            pool,
+	}
+}
+
+// GetOrderById читает заказ и его товары одним обращением к БД: оба
+// подготовленных запроса уходят в одном пакете pgx
+func (r *PoolOrderRepository) GetOrderById(ctx context.Context, id string) (_ *model.Order, err error) {
+	ctx, end := startQuery(ctx, "get_order")
+	defer func() { end(err) }()
+
+	batch := &pgx.Batch{}
+	batch.Queue(getOrderQuery, id)
+	batch.Queue(getOrderItemsQuery, id)
+
+	results := r.pool.SendBatch(ctx, batch)
+	defer func() {
+		if closeErr := results.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error with close batch: %w", closeErr)
+		}
+	}()
+
+	order, err := scanOrder(results.QueryRow())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error with get order query: %w", err)
+	}
+
+	rows, err := results.Query()
+	if err != nil {
+		return nil, fmt.Errorf("error with items query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error with scan item: %w", err)
+		}
+		order.Items = append(order.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error with read items: %w", err)
+	}
+
+	return order, nil
+}
+
+// RegisterPoolMetrics экспортирует статистику пула в метрики
+func RegisterPoolMetrics(pool *pgxpool.Pool) {
+	metrics.RegisterDBPool(func() metrics.DBPoolStats {
+		stat := pool.Stat()
+		return metrics.DBPoolStats{
+			MaxConns:             stat.MaxConns(),
+			TotalConns:           stat.TotalConns(),
+			IdleConns:            stat.IdleConns(),
+			AcquiredConns:        stat.AcquiredConns(),
+			AcquireCount:         stat.AcquireCount(),
+			EmptyAcquireCount:    stat.EmptyAcquireCount(),
+			CanceledAcquireCount: stat.CanceledAcquireCount(),
+			AcquireDuration:      stat.AcquireDuration(),
+		}
+	})
+}
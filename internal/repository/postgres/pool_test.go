@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// fakeSender отдаёт заранее заданные результаты пакета и запоминает сам пакет
+type fakeSender struct {
+	batch   *pgx.Batch
+	results *fakeBatchResults
+}
+
+func (s *fakeSender) SendBatch(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+	s.batch = b
+	return s.results
+}
+
+type fakeBatchResults struct {
+	order    []any
+	orderErr error
+	items    [][]any
+	itemsErr error
+	closed   bool
+}
+
+func (b *fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("unexpected exec")
+}
+
+func (b *fakeBatchResults) Query() (pgx.Rows, error) {
+	if b.itemsErr != nil {
+		return nil, b.itemsErr
+	}
+	return &fakeRows{values: b.items}, nil
+}
+
+func (b *fakeBatchResults) QueryRow() pgx.Row {
+	return fakeRow{values: b.order, err: b.orderErr}
+}
+
+func (b *fakeBatchResults) Close() error {
+	b.closed = true
+	return nil
+}
+
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	return assignValues(dest, r.values)
+}
+
+// fakeRows реализует только методы pgx.Rows, которыми пользуется репозиторий
+type fakeRows struct {
+	pgx.Rows
+	values [][]any
+	pos    int
+}
+
+func (r *fakeRows) Next() bool {
+	r.pos++
+	return r.pos <= len(r.values)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	return assignValues(dest, r.values[r.pos-1])
+}
+
+func (r *fakeRows) Err() error { return nil }
+
+func (r *fakeRows) Close() {}
+
+func assignValues(dest, values []any) error {
+	if len(dest) != len(values) {
+		return fmt.Errorf("scan %d values into %d destinations", len(values), len(dest))
+	}
+	for i, value := range values {
+		target := reflect.ValueOf(dest[i]).Elem()
+		v := reflect.ValueOf(value)
+		if v.Type().AssignableTo(target.Type()) {
+			target.Set(v)
+			continue
+		}
+		scanner, ok := dest[i].(sql.Scanner)
+		if !ok {
+			return fmt.Errorf("cannot assign %T to %s", value, target.Type())
+		}
+		if err := scanner.Scan(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadTestOrder(t *testing.T) model.Order {
+	data, err := os.ReadFile("testdata.json")
+	require.NoError(t, err)
+
+	var order model.Order
+	require.NoError(t, json.Unmarshal(data, &order))
+	return order
+}
+
+// orderValues возвращает колонки заказа в порядке scanOrder. Суммы
+// отдаются строками, как их отдаёт pgx для NUMERIC
+func orderValues(o model.Order) []any {
+	d, p := o.Delivery, o.Payment
+	return []any{
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, o.Status,
+		d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+		p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount.String(),
+		p.PaymentDt, p.Bank, p.DeliveryCost.String(), p.GoodsTotal.String(), p.CustomFee.String(),
+	}
+}
+
+func itemValues(item model.Item) []any {
+	return []any{
+		item.ChrtID, item.TrackNumber, item.Price.String(), item.Rid, item.Name,
+		item.Sale, item.Size, item.TotalPrice.String(), item.NmID, item.Brand, item.Status,
+	}
+}
+
+func TestPoolOrderRepository_GetOrderById(t *testing.T) {
+	testOrder := loadTestOrder(t)
+
+	second := testOrder.Items[0]
+	second.ChrtID++
+	second.Rid += "-2"
+	testOrder.Items = append(testOrder.Items, second)
+
+	items := make([][]any, len(testOrder.Items))
+	for i, item := range testOrder.Items {
+		items[i] = itemValues(item)
+	}
+
+	itemsErr := errors.New("connection reset")
+
+	testTable := []struct {
+		name     string
+		results  *fakeBatchResults
+		expected *model.Order
+		wantErr  error
+	}{
+		{
+			name:     "Order found",
+			results:  &fakeBatchResults{order: orderValues(testOrder), items: items},
+			expected: &testOrder,
+		},
+		{
+			name:    "Order not found",
+			results: &fakeBatchResults{orderErr: pgx.ErrNoRows},
+		},
+		{
+			name:    "Items query error",
+			results: &fakeBatchResults{order: orderValues(testOrder), itemsErr: itemsErr},
+			wantErr: itemsErr,
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &fakeSender{results: tt.results}
+			repo := &PoolOrderRepository{OrderRepository: NewOrderRepository(db), pool: sender}
+
+			order, err := repo.GetOrderById(ctx, testOrder.OrderUID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, order)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, order)
+			}
+
+			// Заказ и товары читаются одним пакетом, который всегда закрывается
+			require.NotNil(t, sender.batch)
+			require.Len(t, sender.batch.QueuedQueries, 2)
+			assert.Equal(t, getOrderQuery, sender.batch.QueuedQueries[0].SQL)
+			assert.Equal(t, getOrderItemsQuery, sender.batch.QueuedQueries[1].SQL)
+			assert.Equal(t, []any{testOrder.OrderUID}, sender.batch.QueuedQueries[1].Arguments)
+			assert.True(t, tt.results.closed)
+		})
+	}
+}
@@ -1,11 +1,19 @@
 package postgres
 
+import "time"
+
+// Settings - подключение к PostgreSQL и параметры пула. Нулевые параметры
+// пула оставляют значения pgxpool по умолчанию
 type Settings struct {
-	User string
-	Pass string
-	Host string
-	Port string
-	Name string
-	SslMode string
-	Reload bool
-}
\ No newline at end of file
+	// DSN - строка подключения в виде URL или key=value
+	DSN string
+	// MaxConns и MinConns ограничивают число подключений в пуле
+	MaxConns int
+	MinConns int
+	// MaxConnLifetime - через сколько после открытия подключение закрывается
+	MaxConnLifetime time.Duration
+	// MaxConnIdleTime - через сколько простоя подключение закрывается
+	MaxConnIdleTime time.Duration
+	// HealthCheckPeriod - как часто проверяются простаивающие подключения
+	HealthCheckPeriod time.Duration
+}
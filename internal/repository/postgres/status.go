@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// UpdateStatus переводит заказ в новый статус, если переход разрешён
+// таблицей переходов, и записывает переход в журнал изменений.
+// Повторная установка текущего статуса ничего не меняет
+func (r *OrderRepository) UpdateStatus(ctx context.Context, orderUID string, status model.OrderStatus) (_ *model.Order, err error) {
+	ctx, end := startQuery(ctx, "update_status")
+	defer func() { end(err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			r.logger.ErrorContext(ctx, "Failed to rollback transaction", "error", rbErr)
+		}
+	}()
+
+	var current model.OrderStatus
+	err = tx.QueryRowContext(ctx,
+		`SELECT status FROM orders WHERE order_uid = $1 FOR UPDATE`, orderUID,
+	).Scan(&current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error updating status of order %s: %w", orderUID, domain.ErrOrderNotFound)
+		}
+		return nil, fmt.Errorf("error with select order status: %w", err)
+	}
+
+	if current != status {
+		if !current.CanTransitionTo(status) {
+			return nil, fmt.Errorf("%w: %s -> %s", domain.ErrInvalidTransition, current, status)
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE orders SET status = $1 WHERE order_uid = $2`, status, orderUID)
+		if err != nil {
+			return nil, fmt.Errorf("error with update order status: %w", err)
+		}
+
+		err = recordEvent(ctx, tx, orderUID, domain.EventStatusChanged, domain.SourceFrom(ctx),
+			statusValue{Status: current}, statusValue{Status: status},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := getOrder(ctx, tx, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("error with load updated order: %w", err)
+	}
+	if order == nil {
+		return nil, fmt.Errorf("error updating status of order %s: %w", orderUID, domain.ErrOrderNotFound)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing trans: %w", err)
+	}
+
+	return order, nil
+}
@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func TestOrderRepository_UpdateStatus(t *testing.T) {
+	testOrder := loadTestOrder(t)
+	uid := testOrder.OrderUID
+
+	selectStatus := `SELECT status FROM orders WHERE order_uid = \$1 FOR UPDATE`
+
+	// expectReload - чтение обновлённого заказа в той же транзакции
+	expectReload := func(mock sqlmock.Sqlmock, status model.OrderStatus) {
+		reloaded := testOrder
+		reloaded.Status = status
+		mock.ExpectQuery(`FROM orders o JOIN deliveries d`).WithArgs(uid).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"order_uid", "track_number", "entry", "locale", "internal_signature",
+				"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status",
+				"name", "phone", "zip", "city", "address", "region", "email",
+				"transaction", "request_id", "currency", "provider", "amount",
+				"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+			}).AddRow(rowValues(orderValues(reloaded))...))
+		mock.ExpectQuery(`FROM items WHERE order_uid = \$1 ORDER BY id`).WithArgs(uid).
+			WillReturnRows(sqlmock.NewRows([]string{
+				"chrt_id", "track_number", "price", "rid", "name",
+				"sale", "size", "total_price", "nm_id", "brand", "status",
+			}).AddRow(rowValues(itemValues(testOrder.Items[0]))...))
+	}
+
+	testTable := []struct {
+		name       string
+		status     model.OrderStatus
+		mockDB     func(mock sqlmock.Sqlmock)
+		wantStatus model.OrderStatus
+		wantErr    error
+	}{
+		{
+			name:   "Allowed transition",
+			status: model.StatusPaid,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectStatus).WithArgs(uid).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("created"))
+				mock.ExpectExec(`UPDATE orders SET status = \$1 WHERE order_uid = \$2`).
+					WithArgs(model.StatusPaid, uid).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO order_events`).
+					WithArgs(uid, domain.EventStatusChanged, domain.SourceHTTP, "req-1",
+						`{"status":"created"}`, `{"status":"paid"}`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectReload(mock, model.StatusPaid)
+				mock.ExpectCommit()
+			},
+			wantStatus: model.StatusPaid,
+		},
+		{
+			name:   "Same status is not recorded",
+			status: model.StatusPaid,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectStatus).WithArgs(uid).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("paid"))
+				expectReload(mock, model.StatusPaid)
+				mock.ExpectCommit()
+			},
+			wantStatus: model.StatusPaid,
+		},
+		{
+			name:   "Forbidden transition",
+			status: model.StatusShipped,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectStatus).WithArgs(uid).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("cancelled"))
+				mock.ExpectRollback()
+			},
+			wantErr: domain.ErrInvalidTransition,
+		},
+		{
+			name:   "Order not found",
+			status: model.StatusPaid,
+			mockDB: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectStatus).WithArgs(uid).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			wantErr: domain.ErrOrderNotFound,
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.mockDB(mock)
+
+			sourceCtx := domain.WithSource(ctx, domain.Source{Type: domain.SourceHTTP, ID: "req-1"})
+			order, err := NewOrderRepository(db).UpdateStatus(sourceCtx, uid, tt.status)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, order)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantStatus, order.Status)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
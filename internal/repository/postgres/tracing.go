@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sayhellolexa/order-service/internal/metrics"
+	"github.com/sayhellolexa/order-service/internal/tracing"
+)
+
+var tracer = otel.Tracer("github.com/sayhellolexa/order-service/internal/repository/postgres")
+
+// startQuery начинает спан обращения к БД. Возвращённая функция завершает
+// спан и записывает длительность обращения в метрики
+func startQuery(ctx context.Context, query string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "postgres "+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBOperationName(query)),
+	)
+
+	return ctx, func(err error) {
+		metrics.ObserveQuery(query, start)
+		tracing.End(span, err)
+	}
+}
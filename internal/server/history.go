@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+)
+
+type orderHistoryResponse struct {
+	OrderUID string         `json:"order_uid"`
+	Events   []domain.Event `json:"events"`
+}
+
+// orderHistoryHandler возвращает журнал изменений заказа. Журнал читается
+// только из БД: в кеше хранится лишь текущее состояние заказа
+func (s *server) orderHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["order_uid"]
+
+	events, err := s.pgRepo.GetOrderHistory(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			http.Error(w, "order not found", http.StatusNotFound)
+			return
+		}
+		s.logger.ErrorContext(r.Context(), "Error getting order history", "order_uid", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(orderHistoryResponse{OrderUID: id, Events: events}); err != nil {
+		s.logger.ErrorContext(r.Context(), "Error encoding JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// orderListResponse - конверт ответа GET /orders. NextCursor пуст на последней странице
+type orderListResponse struct {
+	Orders     []*model.Order `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
+	PageSize   int            `json:"page_size"`
+}
+
+func (s *server) listOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := s.parseListFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.pgRepo.ListOrders(r.Context(), filter)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "Error listing orders", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := orderListResponse{
+		Orders:   page.Orders,
+		HasMore:  page.Next != nil,
+		PageSize: filter.Limit,
+	}
+	if resp.Orders == nil {
+		resp.Orders = []*model.Order{}
+	}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.ErrorContext(r.Context(), "Error encoding JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// parseListFilter разбирает параметры запроса GET /orders
+func (s *server) parseListFilter(q url.Values) (domain.ListFilter, error) {
+	filter := domain.ListFilter{
+		CustomerID:      q.Get("customer_id"),
+		TrackNumber:     q.Get("track_number"),
+		DeliveryService: q.Get("delivery_service"),
+		Currency:        q.Get("currency"),
+		Brand:           q.Get("brand"),
+		Limit:           s.pageSize,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return filter, errors.New("limit must be a positive integer")
+		}
+		filter.Limit = min(limit, s.maxPageSize)
+	}
+
+	var err error
+	if filter.CreatedFrom, err = parseTime(q, "created_from"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = parseTime(q, "created_to"); err != nil {
+		return filter, err
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		if filter.After, err = domain.DecodeCursor(v); err != nil {
+			return filter, errors.New("invalid cursor")
+		}
+	}
+
+	return filter, nil
+}
+
+// parseTime разбирает необязательный параметр в формате RFC 3339. Время
+// приводится к UTC: date_created хранится без часового пояса, и без этого
+// фильтр сравнивал бы его с местным временем клиента, а не с моментом
+func parseTime(q url.Values, name string) (time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be in RFC 3339 format", name)
+	}
+	return t.UTC(), nil
+}
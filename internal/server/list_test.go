@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func TestParseListFilter(t *testing.T) {
+	s := NewServer(&stubRepo{}, &stubCache{}, WithPageSize(20, 100))
+
+	cursor := domain.Cursor{DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 500000000, time.UTC), OrderUID: "b"}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    domain.ListFilter
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			want:  domain.ListFilter{Limit: 20},
+		},
+		{
+			name:  "filters and cursor",
+			query: "customer_id=c1&brand=Adidas&created_from=2021-11-01T00:00:00Z&created_to=2021-12-01T00:00:00%2B03:00&limit=5&cursor=" + cursor.Encode(),
+			want: domain.ListFilter{
+				CustomerID:  "c1",
+				Brand:       "Adidas",
+				CreatedFrom: time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:   time.Date(2021, 11, 30, 21, 0, 0, 0, time.UTC),
+				After:       &cursor,
+				Limit:       5,
+			},
+		},
+		{
+			name:  "offset converted to UTC",
+			query: "created_from=2024-01-01T00:00:00%2B03:00",
+			want:  domain.ListFilter{CreatedFrom: time.Date(2023, 12, 31, 21, 0, 0, 0, time.UTC), Limit: 20},
+		},
+		{
+			name:  "limit clamped to max page size",
+			query: "limit=1000",
+			want:  domain.ListFilter{Limit: 100},
+		},
+		{name: "zero limit", query: "limit=0", wantErr: "limit must be a positive integer"},
+		{name: "negative limit", query: "limit=-1", wantErr: "limit must be a positive integer"},
+		{name: "non-numeric limit", query: "limit=ten", wantErr: "limit must be a positive integer"},
+		{name: "invalid cursor", query: "cursor=not-a-cursor", wantErr: "invalid cursor"},
+		{name: "invalid created_from", query: "created_from=2021-11-01", wantErr: "created_from must be in RFC 3339 format"},
+		{name: "invalid created_to", query: "created_to=yesterday", wantErr: "created_to must be in RFC 3339 format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			filter, err := s.parseListFilter(q)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, filter)
+		})
+	}
+}
+
+func TestListOrdersHandler(t *testing.T) {
+	next := &domain.Cursor{DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 123456000, time.UTC), OrderUID: "b"}
+
+	var got domain.ListFilter
+	repo := &stubRepo{
+		listOrders: func(_ context.Context, filter domain.ListFilter) (*domain.OrderPage, error) {
+			got = filter
+			if filter.After != nil {
+				return &domain.OrderPage{}, nil
+			}
+			return &domain.OrderPage{Orders: []*model.Order{{OrderUID: "c"}, {OrderUID: "b"}}, Next: next}, nil
+		},
+	}
+	s := NewServer(repo, &stubCache{})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders?"+query, nil))
+		return rec
+	}
+
+	rec := get("limit=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp orderListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Len(t, resp.Orders, 2)
+	assert.True(t, resp.HasMore)
+	assert.Equal(t, 2, resp.PageSize)
+	assert.Equal(t, next.Encode(), resp.NextCursor)
+
+	// Курсор из ответа возвращается в репозиторий без потерь
+	rec = get("limit=2&cursor=" + resp.NextCursor)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, next, got.After)
+
+	resp = orderListResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotNil(t, resp.Orders)
+	assert.False(t, resp.HasMore)
+	assert.Empty(t, resp.NextCursor)
+
+	for _, query := range []string{"limit=0", "limit=abc", "cursor=not-a-cursor", "created_to=2021-12-01"} {
+		assert.Equal(t, http.StatusBadRequest, get(query).Code, query)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/sayhellolexa/order-service/internal/domain/cache"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// Сколько может длиться загрузка заказа из БД в кеш
+const loadTimeout = 5 * time.Second
+
+// getCached ищет заказ в кеше. Если кеш поддерживает stale-while-revalidate,
+// может вернуть просроченный заказ с флагом stale
+func (s *server) getCached(ctx context.Context, id string) (*model.Order, bool, error) {
+	if staleGetter, ok := s.cache.(cache.StaleGetter); ok {
+		return staleGetter.GetStale(ctx, id)
+	}
+
+	order, err := s.cache.Get(ctx, id)
+	return order, false, err
+}
+
+// loadOrder загружает заказ, которого нет в кеше. Одновременные промахи по
+// одному order_uid объединяются в один запрос к БД и одну запись в кеш
+func (s *server) loadOrder(ctx context.Context, id string) (*model.Order, error) {
+	select {
+	case res := <-s.startLoad(ctx, id):
+		order, _ := res.Val.(*model.Order)
+		return order, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refreshOrder обновляет просроченный заказ в фоне, не дожидаясь результата.
+// Как и при промахе, заказ сначала ищется в кеше: у LocalCache это
+// обновляет копию в памяти из Redis, а БД читается, только если заказа
+// нет и в Redis
+func (s *server) refreshOrder(ctx context.Context, id string) {
+	s.startLoad(ctx, id)
+}
+
+func (s *server) startLoad(ctx context.Context, id string) <-chan singleflight.Result {
+	return s.flight.DoChan(id, func() (any, error) {
+		// Остановка сервера дожидается загрузки, прежде чем закрыть БД и Redis
+		if !s.track() {
+			return nil, errShuttingDown
+		}
+		defer s.background.Done()
+
+		// Загрузку ждут и другие запросы, поэтому она не прерывается
+		// вместе с запросом, который её начал
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		// Пока загрузка ждала своей очереди, заказ мог уже попасть в кеш
+		order, err := s.cache.Get(ctx, id)
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil, nil
+		}
+		if err == nil && order != nil {
+			return order, nil
+		}
+
+		order, err = s.pgRepo.GetOrderById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if order == nil {
+			// Отсутствие заказа кешируется ненадолго, чтобы перебор
+			// несуществующих order_uid не доходил до БД
+			if s.notFoundTTL > 0 {
+				if err := s.cache.SetNotFound(ctx, id, s.notFoundTTL); err != nil {
+					s.logger.ErrorContext(ctx, "Error caching missing order", "order_uid", id, "error", err)
+				}
+			}
+			return nil, nil
+		}
+
+		if err := s.cache.Set(ctx, order); err != nil {
+			s.logger.ErrorContext(ctx, "Error caching order", "order_uid", id, "error", err)
+		} else {
+			s.logger.DebugContext(ctx, "Order successfully cached", "order_uid", id)
+		}
+
+		return order, nil
+	})
+}
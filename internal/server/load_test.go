@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// staleCache отдаёт через GetStale просроченную копию заказа, а через Get -
+// заказ из нижнего уровня кеша
+type staleCache struct {
+	stubCache
+	stale *model.Order
+	fresh *model.Order
+	got   chan struct{}
+}
+
+func (c *staleCache) GetStale(context.Context, string) (*model.Order, bool, error) {
+	return c.stale, true, nil
+}
+
+func (c *staleCache) Get(context.Context, string) (*model.Order, error) {
+	close(c.got)
+	return c.fresh, nil
+}
+
+func TestGetOrderHandler_RevalidatesStaleFromCache(t *testing.T) {
+	cache := &staleCache{
+		stale: &model.Order{OrderUID: "test", Status: model.StatusCreated},
+		fresh: &model.Order{OrderUID: "test", Status: model.StatusPaid},
+		got:   make(chan struct{}),
+	}
+	repo := &stubRepo{
+		getOrder: func(context.Context, string) (*model.Order, error) {
+			t.Error("stale order must be revalidated from the cache while it holds the order")
+			return nil, nil
+		},
+	}
+	s := NewServer(repo, cache)
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/test", nil))
+	<-cache.got
+	s.background.Wait()
+
+	// Клиент сразу получает просроченную копию, а обновление берёт заказ
+	// из кеша и в БД не ходит
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"created"`)
+	assert.Empty(t, cache.set)
+}
@@ -1,75 +1,76 @@
 package server
 
 import (
-	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
-	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
+
+	"github.com/sayhellolexa/order-service/internal/domain/cache"
+	"github.com/sayhellolexa/order-service/internal/health"
 )
 
 func (s *server) configureRoutes() {
-	s.router.Use(corsMiddleware, jsonHeaderMiddleware) 
+	s.router.Use(tracingMiddleware, metricsMiddleware, requestIDMiddleware, corsMiddleware, jsonHeaderMiddleware) 
 
+	s.router.HandleFunc("/orders", s.listOrdersHandler).Methods(http.MethodGet)
 	s.router.HandleFunc("/orders/{order_uid}", s.getOrderHandler).Methods(http.MethodGet)
+	s.router.HandleFunc("/orders/{order_uid}/status", s.updateStatusHandler).Methods(http.MethodPatch, http.MethodOptions)
+	s.router.HandleFunc("/orders/{order_uid}/history", s.orderHistoryHandler).Methods(http.MethodGet)
+
+	s.router.Handle("/healthz", health.LiveHandler()).Methods(http.MethodGet)
+	s.router.Handle("/readyz", s.health.ReadyHandler()).Methods(http.MethodGet)
 }
 
 func (s *server) getOrderHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 	vars := mux.Vars(r)
 	id := vars["order_uid"]
 
-	order, err := s.cache.Get(ctx, id)
+	order, stale, err := s.getCached(ctx, id)
+	if errors.Is(err, cache.ErrNotFound) {
+		http.Error(w, "order not found", http.StatusNotFound)
+		s.logger.DebugContext(ctx, "Order cached as not found", "order_uid", id)
+		return
+	}
 	if err != nil && err != redis.Nil {
-		log.Printf("Error getting order from cache: %v", err)
+		s.logger.ErrorContext(ctx, "Error getting order from cache", "order_uid", id, "error", err)
 	}
 	
 	if order != nil {
-		log.Printf("Order found in cache: %v", order)
+		s.logger.DebugContext(ctx, "Order found in cache", "order", order, "stale", stale)
+		if stale {
+			// Просроченный заказ отдаётся сразу, а обновляется в фоне
+			s.refreshOrder(ctx, id)
+		}
 		if err := json.NewEncoder(w).Encode(order); err != nil {
-			log.Printf("Error encoding JSON: %v", err)
+			s.logger.ErrorContext(ctx, "Error encoding JSON", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return 
 	}
 
-	log.Printf("Order %s not found in cache, checking database", id)
+	s.logger.DebugContext(ctx, "Order not found in cache, checking database", "order_uid", id)
 
-	order, err = s.pgRepo.GetOrderById(ctx, id)
+	order, err = s.loadOrder(ctx, id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("Order %s not found in database", id)
-			http.Error(w, "Order not found in db", http.StatusNotFound)
-			return
-		}
+		s.logger.ErrorContext(ctx, "Error loading order", "order_uid", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
 	if order == nil {
 		http.Error(w, "order not found", http.StatusNotFound)
-		log.Printf("Order %s not found in database", id)
+		s.logger.DebugContext(ctx, "Order not found in database", "order_uid", id)
 		return
 	}
 
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-		defer cancel()
-
-		if err := s.cache.Set(ctx, order, time.Hour * 72); err != nil {
-			log.Printf("Error caching order: %v", err)
-		} else {
-			log.Printf("Order %s successfully cached", order.OrderUID)
-		}
-	}()
-
-	log.Printf("Order %s found in database", id)
+	s.logger.DebugContext(ctx, "Order found in database", "order_uid", id)
 
 	if err := json.NewEncoder(w).Encode(order); err != nil {
-		log.Printf("Error encoding JSON: %v", err)
+		s.logger.ErrorContext(ctx, "Error encoding JSON", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
@@ -2,66 +2,278 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/sync/singleflight"
+
 	"github.com/sayhellolexa/order-service/internal/domain/cache"
 	"github.com/sayhellolexa/order-service/internal/domain/order"
+	"github.com/sayhellolexa/order-service/internal/health"
+)
+
+// Размер страницы списка заказов по умолчанию и максимально допустимый
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
 )
 
+// Как долго по умолчанию кешируется отсутствие заказа. Время жизни
+// самих заказов задаёт политика кеша
+const defaultNotFoundTTL = 30 * time.Second
+
+// Сколько по умолчанию может длиться прогрев кеша при старте
+const defaultPreloadTimeout = 10 * time.Minute
+
+// Таймауты HTTP-сервера по умолчанию
+const (
+	defaultReadTimeout     = 10 * time.Second
+	defaultWriteTimeout    = 15 * time.Second
+	defaultIdleTimeout     = 60 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// errShuttingDown возвращается фоновыми задачами, запущенными во время остановки
+var errShuttingDown = errors.New("server is shutting down")
+
 type server struct {
 	httpServer *http.Server
 	router *mux.Router
 	pgRepo domain.Repository
 	cache cache.Repository
+	pageSize int
+	maxPageSize int
+	notFoundTTL time.Duration
+	preload cache.PreloadOptions
+	preloadTimeout time.Duration
+	// flight объединяет одновременные загрузки одного заказа из БД
+	flight singleflight.Group
+	logger *slog.Logger
+	health *health.Checker
+	// preloaded выставляется, когда прогрев кеша при старте успешно завершён
+	preloaded atomic.Bool
+	// preloadErr - ошибка, которой завершился прогрев
+	preloadErr atomic.Pointer[error]
+	readTimeout time.Duration
+	writeTimeout time.Duration
+	idleTimeout time.Duration
+	shutdownTimeout time.Duration
+	// background учитывает прогрев и загрузки заказов в кеш, которых
+	// остановка должна дождаться. После closing новые задачи не начинаются
+	background sync.WaitGroup
+	backgroundMu sync.Mutex
+	closing bool
+}
+
+// Option настраивает необязательные параметры сервера
+type Option func(*server)
+
+// WithPageSize задаёт размер страницы GET /orders по умолчанию
+// и верхнюю границу для параметра limit
+func WithPageSize(size, max int) Option {
+	return func(s *server) {
+		s.pageSize = size
+		s.maxPageSize = max
+	}
+}
+
+// WithNotFoundTTL задаёт, как долго кешируется отсутствие заказа.
+// Нулевое значение отключает кеширование отсутствующих заказов
+func WithNotFoundTTL(ttl time.Duration) Option {
+	return func(s *server) {
+		s.notFoundTTL = ttl
+	}
+}
+
+// WithLogger задаёт логгер сервера
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *server) {
+		s.logger = logger
+	}
+}
+
+// WithHealth задаёт проверки зависимостей для GET /readyz.
+// Проверка прогрева кеша добавляется к ним сервером
+func WithHealth(checker *health.Checker) Option {
+	return func(s *server) {
+		s.health = checker
+	}
+}
+
+// WithTimeouts задаёт таймауты чтения запроса, записи ответа и простоя
+// keep-alive соединения. Нулевое значение оставляет таймаут по умолчанию
+func WithTimeouts(read, write, idle time.Duration) Option {
+	return func(s *server) {
+		if read > 0 {
+			s.readTimeout = read
+		}
+		if write > 0 {
+			s.writeTimeout = write
+		}
+		if idle > 0 {
+			s.idleTimeout = idle
+		}
+	}
+}
+
+// WithShutdownTimeout задаёт, сколько при остановке ждать текущих
+// запросов и фоновых загрузок
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *server) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// WithPreload задаёт параметры прогрева кеша при старте и его предельную длительность
+func WithPreload(opts cache.PreloadOptions, timeout time.Duration) Option {
+	return func(s *server) {
+		s.preload = opts
+		s.preloadTimeout = timeout
+	}
 }
 
-func NewServer(pgRepo domain.Repository, cacheRepo cache.Repository) *server {
+func NewServer(pgRepo domain.Repository, cacheRepo cache.Repository, opts ...Option) *server {
 	s := &server{
 		router: mux.NewRouter(),
 		pgRepo: pgRepo,
 		cache: cacheRepo,
+		pageSize: defaultPageSize,
+		maxPageSize: maxPageSize,
+		notFoundTTL: defaultNotFoundTTL,
+		preload: cache.PreloadOptions{IfEmpty: true},
+		preloadTimeout: defaultPreloadTimeout,
+		logger: slog.Default(),
+		readTimeout: defaultReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+		idleTimeout: defaultIdleTimeout,
+		shutdownTimeout: defaultShutdownTimeout,
 	}
 
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.health == nil {
+		s.health = health.NewChecker(health.DefaultTimeout)
+	}
+	s.health.Add("cache_preload", s.checkPreload)
+
 	s.configureRoutes()
 	return s
 }
 
-func (s *server) Start(addr string) error {
+// Start обслуживает запросы на addr, пока не будет отменён ctx. После отмены
+// сервер перестаёт принимать соединения, прерывает прогрев кеша и не дольше
+// shutdownTimeout ждёт текущих запросов и фоновых загрузок заказов в кеш
+func (s *server) Start(ctx context.Context, addr string) error {
 	s.httpServer = &http.Server{
 		Addr: addr,
 		Handler: s.router,
+		ReadHeaderTimeout: s.readTimeout,
+		ReadTimeout: s.readTimeout,
+		WriteTimeout: s.writeTimeout,
+		IdleTimeout: s.idleTimeout,
 	}
 
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30 * time.Second)
-		defer cancel()
-
-		count, err := s.cache.Count(ctx) // метод, который вернёт количество ключей в Redis
-		if err != nil {
-			log.Printf("failed to check cache: %v", err)
+	preloadCtx, cancelPreload := context.WithTimeout(context.WithoutCancel(ctx), s.preloadTimeout)
+	defer cancelPreload()
+	s.goBackground(func() {
+		// Прогревается только пустой кеш, а прерванный прогрев продолжается
+		if err := s.cache.PreloadFromDatabase(preloadCtx, s.preload); err != nil {
+			s.logger.Error("Failed to preload cache", "error", err)
+			s.preloadErr.Store(&err)
 			return
 		}
+		s.preloaded.Store(true)
+	})
 
-		if count == 0 {
-			log.Println("Cache is empty → start preload from DB")
-			if err := s.cache.PreloadFromDatabase(ctx, 100); err != nil {
-				log.Printf("failed to preload cache: %v", err)
-			} else {
-				log.Println("Cache preloaded successfully")
-			}
-		} else {
-			log.Printf("Cache already has %d records, preload skipped", count)
-		}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.httpServer.ListenAndServe()
 	}()
+	s.logger.Info("HTTP server is listening", "addr", addr)
 
-	err := s.httpServer.ListenAndServe()
-	if err != nil {
-		return fmt.Errorf("error with start http server: %w", err)
+	var err error
+	select {
+	case err = <-serveErr:
+		err = fmt.Errorf("error with start http server: %w", err)
+	case <-ctx.Done():
+		s.logger.Info("Shutting down HTTP server")
 	}
 
+	cancelPreload()
+	return errors.Join(err, s.shutdown())
+}
+
+// shutdown дожидается текущих запросов, а затем фоновых задач
+func (s *server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("error with shutdown http server: %w", err))
+	}
+
+	s.backgroundMu.Lock()
+	s.closing = true
+	s.backgroundMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, errors.New("error with shutdown: background tasks did not finish in time"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// goBackground запускает фоновую задачу, которую дождётся остановка сервера
+func (s *server) goBackground(task func()) {
+	if !s.track() {
+		return
+	}
+
+	go func() {
+		defer s.background.Done()
+		task()
+	}()
+}
+
+// track учитывает начало фоновой задачи. Возвращает false, если сервер
+// уже останавливается и задачу начинать не нужно
+func (s *server) track() bool {
+	s.backgroundMu.Lock()
+	defer s.backgroundMu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.background.Add(1)
+	return true
+}
+
+// checkPreload не пускает трафик, пока кеш прогревается. После ошибки
+// прогрева сервер остаётся неготовым: холодный кеш не защищает БД от
+// нагрузки, а сама ошибка видна в ответе /readyz
+func (s *server) checkPreload(context.Context) error {
+	if err := s.preloadErr.Load(); err != nil {
+		return fmt.Errorf("cache preload failed: %w", *err)
+	}
+	if !s.preloaded.Load() {
+		return errors.New("cache preload in progress")
+	}
 	return nil
 }
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sayhellolexa/order-service/internal/domain/cache"
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// stubRepo подменяет репозиторий заказов. Методы без заданной функции
+// вызываться в тесте не должны
+type stubRepo struct {
+	domain.Repository
+	getOrder     func(ctx context.Context, id string) (*model.Order, error)
+	listOrders   func(ctx context.Context, filter domain.ListFilter) (*domain.OrderPage, error)
+	updateStatus func(ctx context.Context, orderUID string, status model.OrderStatus) (*model.Order, error)
+}
+
+func (r *stubRepo) GetOrderById(ctx context.Context, id string) (*model.Order, error) {
+	return r.getOrder(ctx, id)
+}
+
+func (r *stubRepo) ListOrders(ctx context.Context, filter domain.ListFilter) (*domain.OrderPage, error) {
+	return r.listOrders(ctx, filter)
+}
+
+func (r *stubRepo) UpdateStatus(ctx context.Context, orderUID string, status model.OrderStatus) (*model.Order, error) {
+	return r.updateStatus(ctx, orderUID, status)
+}
+
+// stubCache - кеш без заказов. preload вызывается при старте сервера
+type stubCache struct {
+	cache.Repository
+	preload func(ctx context.Context, opts cache.PreloadOptions) error
+	set     []*model.Order
+}
+
+func (c *stubCache) Get(context.Context, string) (*model.Order, error) {
+	return nil, nil
+}
+
+func (c *stubCache) Set(_ context.Context, order *model.Order) error {
+	c.set = append(c.set, order)
+	return nil
+}
+
+func (c *stubCache) SetNotFound(context.Context, string, time.Duration) error {
+	return nil
+}
+
+func (c *stubCache) PreloadFromDatabase(ctx context.Context, opts cache.PreloadOptions) error {
+	if c.preload == nil {
+		return nil
+	}
+	return c.preload(ctx, opts)
+}
+
+// freeAddr возвращает адрес со свободным портом для Start
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+// startServer запускает сервер и ждёт, пока он начнёт принимать соединения
+func startServer(t *testing.T, s *server) (addr string, stop context.CancelFunc, stopped <-chan error) {
+	addr = freeAddr(t)
+	ctx, stop := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- s.Start(ctx, addr) }()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	return addr, stop, done
+}
+
+func TestServer_ShutdownDrainsRequests(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	repo := &stubRepo{
+		listOrders: func(context.Context, domain.ListFilter) (*domain.OrderPage, error) {
+			close(entered)
+			<-release
+			return &domain.OrderPage{}, nil
+		},
+	}
+	s := NewServer(repo, &stubCache{})
+	addr, stop, stopped := startServer(t, s)
+
+	respCode := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/orders")
+		if err != nil {
+			respCode <- 0
+			return
+		}
+		resp.Body.Close()
+		respCode <- resp.StatusCode
+	}()
+	<-entered
+
+	stop()
+
+	// Остановка ждёт запрос, который уже обрабатывается
+	select {
+	case err := <-stopped:
+		t.Fatalf("server stopped before in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-respCode)
+	assert.NoError(t, <-stopped)
+}
+
+func TestServer_ShutdownWaitsForBackground(t *testing.T) {
+	preloadStopped := make(chan struct{})
+	stubCache := &stubCache{
+		preload: func(ctx context.Context, _ cache.PreloadOptions) error {
+			<-ctx.Done()
+			close(preloadStopped)
+			return ctx.Err()
+		},
+	}
+
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	repo := &stubRepo{
+		getOrder: func(context.Context, string) (*model.Order, error) {
+			close(loading)
+			<-release
+			return &model.Order{OrderUID: "test"}, nil
+		},
+	}
+
+	s := NewServer(repo, stubCache)
+	addr, stop, stopped := startServer(t, s)
+
+	// Клиент не дождался ответа, но загрузка заказа в кеш продолжается
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, "http://"+addr+"/orders/test", nil)
+	require.NoError(t, err)
+	go func() {
+		<-loading
+		cancelReq()
+	}()
+	_, err = http.DefaultClient.Do(req)
+	require.ErrorIs(t, err, context.Canceled)
+
+	stop()
+
+	// Прогрев прерывается остановкой, а загрузку остановка дожидается
+	<-preloadStopped
+	select {
+	case err := <-stopped:
+		t.Fatalf("server stopped before background load finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-stopped)
+	require.Len(t, stubCache.set, 1)
+	assert.Equal(t, "test", stubCache.set[0].OrderUID)
+}
+
+func TestServer_ReadyAfterPreload(t *testing.T) {
+	tests := []struct {
+		name       string
+		preloadErr error
+		wantCode   int
+	}{
+		{name: "preload finished", wantCode: http.StatusOK},
+		{name: "preload failed", preloadErr: errors.New("database unavailable"), wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preloaded := make(chan struct{})
+			stubCache := &stubCache{
+				preload: func(context.Context, cache.PreloadOptions) error {
+					defer close(preloaded)
+					return tt.preloadErr
+				},
+			}
+			s := NewServer(&stubRepo{}, stubCache)
+			_, stop, stopped := startServer(t, s)
+			defer func() {
+				stop()
+				<-stopped
+			}()
+
+			<-preloaded
+			require.Eventually(t, func() bool {
+				return s.preloaded.Load() || s.preloadErr.Load() != nil
+			}, time.Second, time.Millisecond)
+
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.preloadErr != nil {
+				assert.Contains(t, rec.Body.String(), "database unavailable")
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/sayhellolexa/order-service/internal/domain/cache"
+)
+
+// CacheStatsHandler возвращает долю попаданий по уровням кеша. Это
+// служебная информация: она отдаётся на порту метрик, а не в публичном API
+func CacheStatsHandler(cacheRepo cache.Repository, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := []cache.TierStats{}
+		if reporter, ok := cacheRepo.(cache.StatsReporter); ok {
+			stats = reporter.Stats()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			logger.ErrorContext(r.Context(), "Error encoding JSON", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	})
+}
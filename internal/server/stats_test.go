@@ -0,0 +1,35 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sayhellolexa/order-service/internal/domain/cache"
+)
+
+type statsCache struct {
+	stubCache
+}
+
+func (statsCache) Stats() []cache.TierStats {
+	return []cache.TierStats{cache.NewTierStats("local", 3, 1)}
+}
+
+func TestCacheStatsHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	CacheStatsHandler(&statsCache{}, slog.Default()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cache/stats", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"tier":"local","hits":3,"misses":1,"hit_rate":0.75}]`, rec.Body.String())
+
+	// Публичный API статистику кеша не отдаёт
+	s := NewServer(&stubRepo{}, &statsCache{})
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cache/stats", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+type updateStatusRequest struct {
+	Status string `json:"status"`
+}
+
+func (s *server) updateStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["order_uid"]
+
+	var req updateStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status, err := model.ParseOrderStatus(req.Status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	order, err := s.pgRepo.UpdateStatus(r.Context(), id, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrOrderNotFound):
+			http.Error(w, "order not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			s.logger.ErrorContext(r.Context(), "Error updating order status", "order_uid", id, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "Order status changed", "order_uid", id, "status", order.Status)
+
+	// Кеш обновляется сразу, чтобы следующий GET не вернул старый статус
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5 * time.Second)
+	defer cancel()
+
+	if err := s.cache.Set(ctx, order); err != nil {
+		s.logger.ErrorContext(ctx, "Error caching order", "order_uid", id, "error", err)
+	}
+
+	if err := json.NewEncoder(w).Encode(order); err != nil {
+		s.logger.ErrorContext(ctx, "Error encoding JSON", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
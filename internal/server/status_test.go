@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/sayhellolexa/order-service/internal/domain/order"
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func TestUpdateStatusHandler(t *testing.T) {
+	testTable := []struct {
+		name     string
+		body     string
+		repoErr  error
+		wantCode int
+		wantRepo bool
+	}{
+		{name: "Status changed", body: `{"status":"paid"}`, wantCode: http.StatusOK, wantRepo: true},
+		{name: "Invalid body", body: `{"status":`, wantCode: http.StatusBadRequest},
+		{name: "Unknown status", body: `{"status":"lost"}`, wantCode: http.StatusBadRequest},
+		{
+			name: "Order not found", body: `{"status":"paid"}`, wantRepo: true,
+			repoErr:  fmt.Errorf("error updating status of order test: %w", domain.ErrOrderNotFound),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "Forbidden transition", body: `{"status":"paid"}`, wantRepo: true,
+			repoErr:  fmt.Errorf("%w: cancelled -> paid", domain.ErrInvalidTransition),
+			wantCode: http.StatusConflict,
+		},
+		{
+			name: "Database error", body: `{"status":"paid"}`, wantRepo: true,
+			repoErr:  errors.New("connection refused"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			repo := &stubRepo{
+				updateStatus: func(_ context.Context, orderUID string, status model.OrderStatus) (*model.Order, error) {
+					called = true
+					assert.Equal(t, "test", orderUID)
+					assert.Equal(t, model.StatusPaid, status)
+					if tt.repoErr != nil {
+						return nil, tt.repoErr
+					}
+					return &model.Order{OrderUID: orderUID, Status: status}, nil
+				},
+			}
+			cache := &stubCache{}
+			s := NewServer(repo, cache)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/orders/test/status", strings.NewReader(tt.body))
+			s.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantRepo, called)
+
+			if tt.wantCode != http.StatusOK {
+				assert.Empty(t, cache.set)
+				return
+			}
+
+			// Кеш обновляется сразу, чтобы GET не вернул прежний статус
+			var order model.Order
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &order))
+			assert.Equal(t, model.StatusPaid, order.Status)
+			require.Len(t, cache.set, 1)
+			assert.Equal(t, model.StatusPaid, cache.set[0].Status)
+		})
+	}
+}
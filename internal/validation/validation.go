@@ -0,0 +1,158 @@
+// Package validation проверяет заказ целиком и возвращает все найденные
+// нарушения сразу, а не только первое
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+// Допустимое расхождение total_price с ценой со скидкой: цена после скидки
+// округляется. Остальные суммы хранятся точно и должны совпадать
+const discountTolerance = model.Unit
+
+var (
+	// Телефон в формате E.164, плюс можно не указывать
+	phonePattern = regexp.MustCompile(`^\+?[1-9][0-9]{6,14}$`)
+	// Локаль - код языка ISO 639-1, при необходимости с регионом: ru, en-US, pt_BR
+	localePattern = regexp.MustCompile(`^[a-z]{2}([-_][A-Z]{2})?$`)
+)
+
+// FieldError - нарушение правила в конкретном поле заказа. Field - путь
+// к полю в JSON заказа, например payment.currency или items[0].total_price
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors - все нарушения, найденные в заказе
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validator накапливает нарушения по мере проверки
+type validator struct {
+	errs Errors
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.add(field, "is required")
+	}
+}
+
+func (v *validator) nonNegative(field string, value model.Money) {
+	if value < 0 {
+		v.add(field, "cannot be negative")
+	}
+}
+
+// ValidateOrder проверяет обязательные поля, форматы и согласованность
+// сумм заказа. Возвращает Errors со всеми нарушениями или nil
+func ValidateOrder(o *model.Order) error {
+	v := &validator{}
+
+	v.required("order_uid", o.OrderUID)
+	v.required("track_number", o.TrackNumber)
+	v.required("customer_id", o.CustomerID)
+
+	if o.Locale != "" && !localePattern.MatchString(o.Locale) {
+		v.add("locale", "%q is not a valid locale", o.Locale)
+	}
+
+	v.validateDelivery(&o.Delivery)
+	v.validatePayment(&o.Payment)
+
+	var itemsTotal model.Money
+	for i := range o.Items {
+		v.validateItem(fmt.Sprintf("items[%d]", i), &o.Items[i], o.TrackNumber)
+		itemsTotal += o.Items[i].TotalPrice
+	}
+
+	p := o.Payment
+	if p.GoodsTotal != itemsTotal {
+		v.add("payment.goods_total", "%s does not match sum of items total_price %s", p.GoodsTotal, itemsTotal)
+	}
+	if expected := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != expected {
+		v.add("payment.amount", "%s does not match goods_total + delivery_cost + custom_fee = %s", p.Amount, expected)
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (v *validator) validateDelivery(d *model.Delivery) {
+	v.required("delivery.name", d.Name)
+	v.required("delivery.address", d.Address)
+
+	if d.Phone != "" && !phonePattern.MatchString(d.Phone) {
+		v.add("delivery.phone", "%q is not a valid phone number", d.Phone)
+	}
+	if d.Email != "" && !validEmail(d.Email) {
+		v.add("delivery.email", "%q is not a valid email", d.Email)
+	}
+}
+
+func (v *validator) validatePayment(p *model.Payment) {
+	v.required("payment.transaction", p.Transaction)
+
+	if !currencies[p.Currency] {
+		v.add("payment.currency", "%q is not an ISO 4217 currency code", p.Currency)
+	}
+
+	v.nonNegative("payment.amount", p.Amount)
+	v.nonNegative("payment.delivery_cost", p.DeliveryCost)
+	v.nonNegative("payment.goods_total", p.GoodsTotal)
+	v.nonNegative("payment.custom_fee", p.CustomFee)
+}
+
+func (v *validator) validateItem(path string, item *model.Item, trackNumber string) {
+	if item.ChrtID == 0 {
+		v.add(path+".chrt_id", "is required")
+	}
+	v.required(path+".name", item.Name)
+
+	if item.TrackNumber != trackNumber {
+		v.add(path+".track_number", "%q does not match order track_number %q", item.TrackNumber, trackNumber)
+	}
+
+	v.nonNegative(path+".price", item.Price)
+	v.nonNegative(path+".total_price", item.TotalPrice)
+
+	if item.Sale < 0 || item.Sale > 100 {
+		v.add(path+".sale", "%d is out of range 0..100", item.Sale)
+		return
+	}
+
+	// Сравнение в сотых долях копейки, чтобы не округлять цену со скидкой
+	expected := item.Price * model.Money(100-item.Sale)
+	if diff := item.TotalPrice*100 - expected; diff < -discountTolerance*100 || diff > discountTolerance*100 {
+		v.add(path+".total_price", "%s does not match price %s with sale %d%%", item.TotalPrice, item.Price, item.Sale)
+	}
+}
+
+// validEmail проверяет, что строка - голый адрес без отображаемого имени
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	model "github.com/sayhellolexa/order-service/internal/model"
+)
+
+func validOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "123",
+		CustomerID:  "cust1",
+		TrackNumber: "TRACK-123",
+		Locale:      "en",
+		Delivery: model.Delivery{
+			Name:    "John",
+			Phone:   "+9720012345",
+			Address: "Street",
+			City:    "City",
+			Email:   "john@example.com",
+		},
+		Payment: model.Payment{
+			Transaction:  "tx123",
+			Currency:     "USD",
+			Provider:     "bank",
+			Amount:       1817 * model.Unit,
+			DeliveryCost: 1500 * model.Unit,
+			GoodsTotal:   317 * model.Unit,
+		},
+		Items: []model.Item{
+			{ChrtID: 1, Name: "item", TrackNumber: "TRACK-123", Price: 453 * model.Unit, Sale: 30, TotalPrice: 317 * model.Unit},
+		},
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(o *model.Order)
+		wantFields []string
+	}{
+		{
+			name:   "valid order",
+			modify: func(o *model.Order) {},
+		},
+		{
+			name: "empty order",
+			modify: func(o *model.Order) {
+				*o = model.Order{}
+			},
+			wantFields: []string{
+				"order_uid", "track_number", "customer_id",
+				"delivery.name", "delivery.address",
+				"payment.transaction", "payment.currency",
+			},
+		},
+		{
+			name: "invalid formats",
+			modify: func(o *model.Order) {
+				o.Locale = "Russian"
+				o.Delivery.Phone = "12345"
+				o.Delivery.Email = "John <john@example.com>"
+				o.Payment.Currency = "usd"
+			},
+			wantFields: []string{"locale", "delivery.phone", "delivery.email", "payment.currency"},
+		},
+		{
+			name: "inconsistent totals",
+			modify: func(o *model.Order) {
+				o.Items[0].TotalPrice = 400 * model.Unit
+				o.Items[0].TrackNumber = "OTHER"
+				o.Payment.Amount = 1000 * model.Unit
+			},
+			wantFields: []string{
+				"items[0].track_number", "items[0].total_price",
+				"payment.goods_total", "payment.amount",
+			},
+		},
+		{
+			name: "amount off by one cent",
+			modify: func(o *model.Order) {
+				o.Payment.Amount++
+			},
+			wantFields: []string{"payment.amount"},
+		},
+		{
+			name: "sale out of range",
+			modify: func(o *model.Order) {
+				o.Items[0].Sale = 150
+			},
+			wantFields: []string{"items[0].sale"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(order)
+
+			err := ValidateOrder(order)
+			if len(tt.wantFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var errs Errors
+			require.ErrorAs(t, err, &errs)
+
+			fields := make([]string, len(errs))
+			for i, fieldErr := range errs {
+				fields[i] = fieldErr.Field
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 -- +goose Up
+-- Внешний ключ на orders добавляет миграция 202508261311: эта выполняется
+-- раньше, чем создаётся orders
 CREATE TABLE IF NOT EXISTS deliveries (
-    order_uid VARCHAR(50) PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+    order_uid VARCHAR(50) PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
     phone VARCHAR(20) NOT NULL,
     zip VARCHAR(20) NOT NULL,
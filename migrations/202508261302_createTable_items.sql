@@ -1,7 +1,9 @@
 -- +goose Up
+-- Внешний ключ на orders добавляет миграция 202508261311: эта выполняется
+-- раньше, чем создаётся orders
 CREATE TABLE IF NOT EXISTS items (
     id SERIAL PRIMARY KEY,
-    order_uid VARCHAR(50) NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    order_uid VARCHAR(50) NOT NULL,
     chrt_id BIGINT NOT NULL,
     track_number VARCHAR(50) NOT NULL,
     price DECIMAL(12, 2) NOT NULL,
@@ -1,6 +1,8 @@
 -- +goose Up
+-- Внешний ключ на orders добавляет миграция 202508261311: эта выполняется
+-- раньше, чем создаётся orders
 CREATE TABLE IF NOT EXISTS payments (
-   order_uid VARCHAR(50) PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+   order_uid VARCHAR(50) PRIMARY KEY,
    transaction VARCHAR(100) NOT NULL,
    request_id VARCHAR(50),
    currency VARCHAR(10) NOT NULL,
@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS payload_hash VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS order_versions (
+    order_uid VARCHAR(50) NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    replaced_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (order_uid, version)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS order_versions;
+ALTER TABLE orders DROP COLUMN IF EXISTS payload_hash;
+ALTER TABLE orders DROP COLUMN IF EXISTS version;
\ No newline at end of file
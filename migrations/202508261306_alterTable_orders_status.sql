@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'created';
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN IF EXISTS status;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(50) NOT NULL,
+    event_type VARCHAR(20) NOT NULL,
+    source_type VARCHAR(20) NOT NULL,
+    source_id VARCHAR(255) NOT NULL DEFAULT '',
+    old_value JSONB,
+    new_value JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS order_events_order_uid_idx ON order_events (order_uid, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS order_events;
\ No newline at end of file
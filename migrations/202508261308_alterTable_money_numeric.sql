@@ -0,0 +1,24 @@
+-- +goose Up
+-- Все денежные суммы хранятся как NUMERIC(14, 2): сумма заказа складывается
+-- из сумм товаров и не должна упираться в их точность. Приложение читает
+-- и пишет их как model.Money - целое число копеек, без float64
+ALTER TABLE items
+    ALTER COLUMN price TYPE NUMERIC(14, 2),
+    ALTER COLUMN total_price TYPE NUMERIC(14, 2);
+
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE NUMERIC(14, 2),
+    ALTER COLUMN delivery_cost TYPE NUMERIC(14, 2),
+    ALTER COLUMN goods_total TYPE NUMERIC(14, 2),
+    ALTER COLUMN custom_fee TYPE NUMERIC(14, 2);
+
+-- +goose Down
+ALTER TABLE payments
+    ALTER COLUMN custom_fee TYPE DECIMAL(12, 2),
+    ALTER COLUMN goods_total TYPE DECIMAL(12, 2),
+    ALTER COLUMN delivery_cost TYPE DECIMAL(12, 2),
+    ALTER COLUMN amount TYPE DECIMAL(12, 2);
+
+ALTER TABLE items
+    ALTER COLUMN total_price TYPE DECIMAL(12, 2),
+    ALTER COLUMN price TYPE DECIMAL(12, 2);
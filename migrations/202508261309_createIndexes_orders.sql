@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders (track_number);
+-- Постраничный список заказов и прогрев кеша идут по (date_created, order_uid)
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
+CREATE UNIQUE INDEX IF NOT EXISTS payments_transaction_key ON payments (transaction);
+
+-- +goose Down
+DROP INDEX IF EXISTS payments_transaction_key;
+DROP INDEX IF EXISTS items_order_uid_idx;
+DROP INDEX IF EXISTS orders_date_created_idx;
+DROP INDEX IF EXISTS orders_track_number_idx;
+DROP INDEX IF EXISTS orders_customer_id_idx;
@@ -0,0 +1,53 @@
+-- +goose Up
+-- Ограничения повторяют правила пакета validation. NOT VALID не проверяет
+-- строки, сохранённые до появления правил, но действует на все новые записи
+ALTER TABLE orders
+    ADD CONSTRAINT orders_order_uid_check CHECK (order_uid <> '') NOT VALID,
+    ADD CONSTRAINT orders_track_number_check CHECK (track_number <> '') NOT VALID,
+    ADD CONSTRAINT orders_customer_id_check CHECK (customer_id <> '') NOT VALID,
+    ADD CONSTRAINT orders_locale_check CHECK (locale = '' OR locale ~ '^[a-z]{2}([-_][A-Z]{2})?$') NOT VALID,
+    ADD CONSTRAINT orders_status_check CHECK (status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned')) NOT VALID,
+    ADD CONSTRAINT orders_version_check CHECK (version >= 1) NOT VALID;
+
+ALTER TABLE deliveries
+    ADD CONSTRAINT deliveries_name_check CHECK (name <> '') NOT VALID,
+    ADD CONSTRAINT deliveries_address_check CHECK (address <> '') NOT VALID,
+    ADD CONSTRAINT deliveries_phone_check CHECK (phone = '' OR phone ~ '^\+?[1-9][0-9]{6,14}$') NOT VALID;
+
+ALTER TABLE payments
+    ADD CONSTRAINT payments_transaction_check CHECK (transaction <> '') NOT VALID,
+    ADD CONSTRAINT payments_currency_check CHECK (currency ~ '^[A-Z]{3}$') NOT VALID,
+    ADD CONSTRAINT payments_amounts_check CHECK (
+        amount >= 0 AND delivery_cost >= 0 AND goods_total >= 0 AND custom_fee >= 0
+    ) NOT VALID;
+
+ALTER TABLE items
+    ADD CONSTRAINT items_chrt_id_check CHECK (chrt_id <> 0) NOT VALID,
+    ADD CONSTRAINT items_name_check CHECK (name <> '') NOT VALID,
+    ADD CONSTRAINT items_prices_check CHECK (price >= 0 AND total_price >= 0) NOT VALID,
+    ADD CONSTRAINT items_sale_check CHECK (sale BETWEEN 0 AND 100) NOT VALID;
+
+-- +goose Down
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS items_sale_check,
+    DROP CONSTRAINT IF EXISTS items_prices_check,
+    DROP CONSTRAINT IF EXISTS items_name_check,
+    DROP CONSTRAINT IF EXISTS items_chrt_id_check;
+
+ALTER TABLE payments
+    DROP CONSTRAINT IF EXISTS payments_amounts_check,
+    DROP CONSTRAINT IF EXISTS payments_currency_check,
+    DROP CONSTRAINT IF EXISTS payments_transaction_check;
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT IF EXISTS deliveries_phone_check,
+    DROP CONSTRAINT IF EXISTS deliveries_address_check,
+    DROP CONSTRAINT IF EXISTS deliveries_name_check;
+
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS orders_version_check,
+    DROP CONSTRAINT IF EXISTS orders_status_check,
+    DROP CONSTRAINT IF EXISTS orders_locale_check,
+    DROP CONSTRAINT IF EXISTS orders_customer_id_check,
+    DROP CONSTRAINT IF EXISTS orders_track_number_check,
+    DROP CONSTRAINT IF EXISTS orders_order_uid_check;
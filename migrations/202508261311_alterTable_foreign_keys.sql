@@ -0,0 +1,27 @@
+-- +goose Up
+-- Миграции 202508261301-202508261303 выполняются до создания orders, поэтому
+-- внешние ключи на orders добавляются здесь. В базах, где таблицы уже были
+-- созданы с ключами, ограничения с теми же именами существуют и пропускаются
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'deliveries_order_uid_fkey') THEN
+        ALTER TABLE deliveries ADD CONSTRAINT deliveries_order_uid_fkey
+            FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'items_order_uid_fkey') THEN
+        ALTER TABLE items ADD CONSTRAINT items_order_uid_fkey
+            FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'payments_order_uid_fkey') THEN
+        ALTER TABLE payments ADD CONSTRAINT payments_order_uid_fkey
+            FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE;
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_order_uid_fkey;
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_order_uid_fkey;
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_order_uid_fkey;
@@ -0,0 +1,10 @@
+// Package migrations встраивает SQL-миграции схемы в бинарники, чтобы они
+// не зависели от рабочего каталога
+package migrations
+
+import "embed"
+
+// FS содержит файлы миграций goose
+//
+//go:embed *.sql
+var FS embed.FS
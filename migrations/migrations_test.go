@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	createTable = regexp.MustCompile(`(?i)CREATE TABLE (?:IF NOT EXISTS )?(\w+)`)
+	references  = regexp.MustCompile(`(?i)REFERENCES (\w+)`)
+)
+
+// Таблица должна создаваться раньше, чем миграции, которые на неё ссылаются,
+// иначе миграции не применятся к пустой базе
+func TestMigrations_ReferencedTablesCreatedFirst(t *testing.T) {
+	names, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+	sort.Strings(names)
+
+	created := make(map[string]bool)
+	for _, name := range names {
+		data, err := fs.ReadFile(FS, name)
+		require.NoError(t, err)
+
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		for _, match := range createTable.FindAllStringSubmatch(up, -1) {
+			created[strings.ToLower(match[1])] = true
+		}
+		for _, ref := range references.FindAllStringSubmatch(up, -1) {
+			assert.True(t, created[strings.ToLower(ref[1])], "%s references %s before it is created", name, ref[1])
+		}
+	}
+}
+
+// Версии, уже применённые в существующих базах, нельзя перенумеровывать:
+// goose откатывает миграцию по номеру версии, и под прежним номером
+// окажется другая таблица
+func TestMigrations_AppliedVersionsUnchanged(t *testing.T) {
+	applied := map[string]string{
+		"202508261301": "deliveries",
+		"202508261302": "items",
+		"202508261303": "payments",
+		"202508261304": "orders",
+		"202508261307": "order_events",
+	}
+
+	for version, table := range applied {
+		names, err := fs.Glob(FS, version+"_*.sql")
+		require.NoError(t, err)
+		require.Len(t, names, 1, "migration %s", version)
+
+		data, err := fs.ReadFile(FS, names[0])
+		require.NoError(t, err)
+
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		match := createTable.FindStringSubmatch(up)
+		require.NotNil(t, match, "%s creates no table", names[0])
+		assert.Equal(t, table, strings.ToLower(match[1]), names[0])
+	}
+}